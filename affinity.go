@@ -0,0 +1,90 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type affinityKey struct{}
+
+// WithAffinityKey returns a copy of ctx pinning the requests made with it to
+// the connections of session, see ConnAffinity
+func WithAffinityKey(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, session)
+}
+
+// NewConnAffinity returns a roundtripper giving every session its own
+// connections, cloned from base
+func NewConnAffinity(base *http.Transport) *ConnAffinity {
+	return &ConnAffinity{Base: base}
+}
+
+// ConnAffinity makes the requests of a session, see WithAffinityKey, reuse the
+// same connections, for stateful backends. Go pools connections by scheme,
+// host and proxy only, so every session gets a transport cloned from Base,
+// with a connection pool of its own. Requests without session are sent
+// through Base, http.DefaultTransport if nil. This is best effort:
+//   - a connection is only reused once idle, concurrent requests of a session
+//     dial extra connections unless Base.MaxConnsPerHost is 1, which makes them
+//     wait for each other
+//   - connections closed by the server, or idle for longer than
+//     Base.IdleConnTimeout, are replaced by new ones
+//   - with HTTP/2, the requests of a session share a single connection
+//
+// Sessions are kept until released with Release
+type ConnAffinity struct {
+	Base *http.Transport
+
+	mu       sync.Mutex // guards sessions
+	sessions map[string]*http.Transport
+}
+
+// RoundTrip sends the request through the transport of its session
+func (a *ConnAffinity) RoundTrip(req *http.Request) (*http.Response, error) {
+	session, _ := req.Context().Value(affinityKey{}).(string)
+	if session == "" {
+		return a.base().RoundTrip(req)
+	}
+	a.mu.Lock()
+	if a.sessions == nil {
+		a.sessions = make(map[string]*http.Transport)
+	}
+	t, ok := a.sessions[session]
+	if !ok {
+		t = a.base().Clone()
+		a.sessions[session] = t
+	}
+	a.mu.Unlock()
+	return t.RoundTrip(req)
+}
+
+// Release forgets session and closes its idle connections, the connections in
+// use are closed once their response is read
+func (a *ConnAffinity) Release(session string) {
+	a.mu.Lock()
+	t, ok := a.sessions[session]
+	delete(a.sessions, session)
+	a.mu.Unlock()
+	if ok {
+		t.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections closes the idle connections of Base and of every
+// session
+func (a *ConnAffinity) CloseIdleConnections() {
+	a.base().CloseIdleConnections()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.sessions {
+		t.CloseIdleConnections()
+	}
+}
+
+func (a *ConnAffinity) base() *http.Transport {
+	if a.Base != nil {
+		return a.Base
+	}
+	return http.DefaultTransport.(*http.Transport)
+}
@@ -0,0 +1,70 @@
+package port
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnAffinity(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client address identifies the connection
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var dials int32
+	dialer := &net.Dialer{}
+	base := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+		MaxIdleConnsPerHost: 10,
+	}
+	a := NewConnAffinity(base)
+	defer a.CloseIdleConnections()
+
+	conn := func(session string) string {
+		ctx := context.Background()
+		if session != "" {
+			ctx = WithAffinityKey(ctx, session)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+		require.NoError(t, err)
+		resp, err := a.RoundTrip(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return string(b)
+	}
+
+	alice := conn("alice")
+	bob := conn("bob")
+	assert.NotEqual(t, alice, bob)
+	assert.Equal(t, alice, conn("alice"))
+	assert.Equal(t, bob, conn("bob"))
+	assert.Equal(t, alice, conn("alice"))
+
+	// requests without session use the pool of base
+	anonymous := conn("")
+	assert.NotEqual(t, alice, anonymous)
+	assert.NotEqual(t, bob, anonymous)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	// a released session starts afresh
+	a.Release("alice")
+	assert.NotEqual(t, alice, conn("alice"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&dials))
+}
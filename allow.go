@@ -0,0 +1,157 @@
+package port
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBlockedHost is matched, with errors.Is, by the *BlockedHostError
+// returned by AllowHosts
+var ErrBlockedHost = errors.New("host is blocked")
+
+// BlockedHostError is returned by AllowHosts for requests to a forbidden host
+type BlockedHostError struct {
+	Host string
+	// IP is the forbidden address the host resolved to, nil when the host
+	// itself is not allowed
+	IP net.IP
+}
+
+func (e *BlockedHostError) Error() string {
+	if e.IP == nil {
+		return "host " + e.Host + " is not allowed"
+	}
+	return "host " + e.Host + " resolves to the internal address " + e.IP.String()
+}
+
+// Is makes errors.Is match ErrBlockedHost
+func (e *BlockedHostError) Is(target error) bool {
+	return target == ErrBlockedHost
+}
+
+// HostResolver resolves host names, *net.Resolver implements it
+type HostResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// AllowRules are the rules enforced by AllowHosts
+type AllowRules struct {
+	// Hosts lists the host names allowed, "*.example.com" allowing every
+	// subdomain of example.com. An empty list allows any host
+	Hosts []string
+	// AllowInternal lets requests reach loopback, private, link-local,
+	// multicast and unspecified addresses
+	AllowInternal bool
+	// Resolver resolves the request host, net.DefaultResolver by default
+	Resolver HostResolver
+}
+
+// AllowHosts returns a RequestModifier rejecting with a *BlockedHostError the
+// requests to a host missing from rules.Hosts, or resolving to an internal
+// address. Every address of the host is checked, and literal IPs as well.
+//
+// The transport resolves the host again when dialing, so a name may resolve
+// to another address by then (DNS rebinding). Use rules.Control as the
+// Control function of the net.Dialer of the base transport to check the
+// address actually dialed as well
+func AllowHosts(rules AllowRules) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		host := strings.ToLower(req.URL.Hostname())
+		if !rules.hostAllowed(host) {
+			return &BlockedHostError{Host: host}
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			return rules.checkIP(host, ip)
+		}
+		resolver := rules.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		addrs, err := resolver.LookupIPAddr(req.Context(), host)
+		if err != nil {
+			return errors.Wrapf(err, "unable to resolve %s", host)
+		}
+		for _, addr := range addrs {
+			if err := rules.checkIP(host, addr.IP); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Control rejects connections to internal addresses, it has the signature of
+// net.Dialer.Control
+func (rules AllowRules) Control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Wrapf(err, "invalid address %s", address)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("address %s is not an IP", address)
+	}
+	return rules.checkIP(host, ip)
+}
+
+func (rules AllowRules) hostAllowed(host string) bool {
+	if len(rules.Hosts) == 0 {
+		return true
+	}
+	for _, h := range rules.Hosts {
+		h = strings.ToLower(h)
+		if suffix, ok := strings.CutPrefix(h, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+func (rules AllowRules) checkIP(host string, ip net.IP) error {
+	if rules.AllowInternal || !isInternalIP(ip) {
+		return nil
+	}
+	return &BlockedHostError{Host: host, IP: ip}
+}
+
+// isInternalIP reports whether ip is not a public unicast address
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// ErrMethodNotAllowed is returned by AllowMethods for requests using another
+// method
+var ErrMethodNotAllowed = errors.New("method not allowed")
+
+// AllowMethods returns a RequestModifier rejecting with ErrMethodNotAllowed the
+// requests whose method is not one of methods, compared case-insensitively. An
+// empty method is GET
+func AllowMethods(methods ...string) RequestModifier {
+	allowed := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		allowed[strings.ToUpper(m)] = true
+	}
+	return RequestModifierFunc(func(req *http.Request) error {
+		method := strings.ToUpper(req.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		if !allowed[method] {
+			return errors.Wrap(ErrMethodNotAllowed, method)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,105 @@
+package port
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticResolver resolves names from a map
+type staticResolver map[string][]string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func allowHostsErr(t *testing.T, rules AllowRules, rawurl string) error {
+	t.Helper()
+	req, err := http.NewRequest("GET", rawurl, nil)
+	require.NoError(t, err)
+	return AllowHosts(rules).Intercept(req)
+}
+
+func TestAllowHosts(t *testing.T) {
+	rules := AllowRules{
+		Hosts: []string{"api.example.com", "*.cdn.example.com", "127.0.0.1", "169.254.169.254", "rebind.example.com"},
+		Resolver: staticResolver{
+			"api.example.com":    {"93.184.216.34"},
+			"a.cdn.example.com":  {"93.184.216.35"},
+			"rebind.example.com": {"93.184.216.36", "10.0.0.1"},
+		},
+	}
+
+	// allowed public hosts
+	assert.NoError(t, allowHostsErr(t, rules, "https://api.example.com/"))
+	assert.NoError(t, allowHostsErr(t, rules, "https://a.cdn.example.com:8443/"))
+
+	for _, rawurl := range []string{
+		"http://127.0.0.1:8080/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://rebind.example.com/",
+	} {
+		err := allowHostsErr(t, rules, rawurl)
+		require.Error(t, err, rawurl)
+		assert.True(t, errors.Is(err, ErrBlockedHost), rawurl)
+		var blocked *BlockedHostError
+		require.True(t, errors.As(err, &blocked), rawurl)
+		assert.NotNil(t, blocked.IP, rawurl)
+	}
+
+	// not in the allowlist
+	err := allowHostsErr(t, rules, "https://cdn.example.com/")
+	var blocked *BlockedHostError
+	require.True(t, errors.As(err, &blocked))
+	assert.Equal(t, "cdn.example.com", blocked.Host)
+	assert.Nil(t, blocked.IP)
+}
+
+func TestAllowHosts_NoAllowlist(t *testing.T) {
+	rules := AllowRules{Resolver: staticResolver{"example.com": {"93.184.216.34"}}}
+	assert.NoError(t, allowHostsErr(t, rules, "https://example.com/"))
+	assert.True(t, errors.Is(allowHostsErr(t, rules, "http://[::1]/"), ErrBlockedHost))
+	assert.True(t, errors.Is(allowHostsErr(t, rules, "http://[fe80::1]/"), ErrBlockedHost))
+	assert.Error(t, allowHostsErr(t, rules, "https://unknown.example.com/"))
+
+	rules.AllowInternal = true
+	assert.NoError(t, allowHostsErr(t, rules, "http://127.0.0.1/"))
+}
+
+func TestAllowRules_Control(t *testing.T) {
+	rules := AllowRules{}
+	assert.NoError(t, rules.Control("tcp", "93.184.216.34:443", nil))
+	assert.True(t, errors.Is(rules.Control("tcp", "10.1.2.3:443", nil), ErrBlockedHost))
+	assert.True(t, errors.Is(rules.Control("tcp6", "[::1]:80", nil), ErrBlockedHost))
+}
+
+func TestAllowMethods(t *testing.T) {
+	mod := AllowMethods("GET", "post")
+	check := func(method string) error {
+		req, err := http.NewRequest(method, "http://example.com", nil)
+		require.NoError(t, err)
+		return mod.Intercept(req)
+	}
+
+	assert.NoError(t, check("GET"))
+	assert.NoError(t, check("POST"))
+	assert.NoError(t, check("get"))
+	assert.NoError(t, check(""))
+
+	err := check("DELETE")
+	assert.True(t, errors.Is(err, ErrMethodNotAllowed))
+	assert.EqualError(t, err, "DELETE: method not allowed")
+}
@@ -0,0 +1,145 @@
+package port
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNoAPIKey is returned by APIKeyPool when every key of the pool is disabled
+var ErrNoAPIKey = errors.New("no api key available")
+
+// Strategy is the way a KeyPool picks the key of a request
+type Strategy int
+
+const (
+	// RoundRobin uses the keys in turn
+	RoundRobin Strategy = iota
+	// Random picks a key uniformly
+	Random
+)
+
+// APIKeyPool returns a KeyPool setting header to one of keys on every
+// request, picked with strategy among the keys that are not disabled
+func APIKeyPool(header string, keys []string, strategy Strategy) *KeyPool {
+	return &KeyPool{
+		header:   header,
+		keys:     append([]string(nil), keys...),
+		strategy: strategy,
+		disabled: make(map[string]bool),
+	}
+}
+
+// KeyPool is a RequestModifier rotating API keys, e.g. to spread the load
+// over several per key rate limits. Keys can be disabled once exhausted and
+// enabled back later. Requests whose context comes from WithStickyAPIKeys keep
+// using the same key per host while it is enabled. It is safe for concurrent
+// use
+type KeyPool struct {
+	header   string
+	keys     []string
+	strategy Strategy
+
+	mu       sync.Mutex // guards the fields below
+	next     int
+	disabled map[string]bool
+}
+
+type stickyKeysKey struct{}
+
+// stickyKeys are the keys used by the requests of a context, per host
+type stickyKeys struct {
+	mu     sync.Mutex
+	byHost map[string]string
+}
+
+// WithStickyAPIKeys returns a copy of ctx making the requests sent with it
+// reuse the key of the first one to the same host, as long as that key is
+// enabled
+func WithStickyAPIKeys(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyKeysKey{}, &stickyKeys{byHost: make(map[string]string)})
+}
+
+// Intercept sets the header of req to a key of the pool
+func (p *KeyPool) Intercept(req *http.Request) error {
+	sticky, _ := req.Context().Value(stickyKeysKey{}).(*stickyKeys)
+	if sticky != nil {
+		sticky.mu.Lock()
+		defer sticky.mu.Unlock()
+		if key, ok := sticky.byHost[req.URL.Host]; ok && p.enabled(key) {
+			req.Header.Set(p.header, key)
+			return nil
+		}
+	}
+	key, err := p.pick()
+	if err != nil {
+		return err
+	}
+	if sticky != nil {
+		sticky.byHost[req.URL.Host] = key
+	}
+	req.Header.Set(p.header, key)
+	return nil
+}
+
+// Disable stops using key until Enable is called
+func (p *KeyPool) Disable(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disabled[key] = true
+}
+
+// Enable uses key again
+func (p *KeyPool) Enable(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.disabled, key)
+}
+
+// DisableOn returns a ResponseModifier disabling the key of the request when
+// exhausted reports true for its response, e.g. on 429 Too Many Requests
+func (p *KeyPool) DisableOn(exhausted func(*http.Response) bool) ResponseModifier {
+	return ResponseModifierFunc(func(res *http.Response) error {
+		if res.Request == nil || !exhausted(res) {
+			return nil
+		}
+		if key := res.Request.Header.Get(p.header); key != "" {
+			p.Disable(key)
+		}
+		return nil
+	})
+}
+
+func (p *KeyPool) enabled(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.disabled[key]
+}
+
+func (p *KeyPool) pick() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.strategy == Random {
+		var enabled []string
+		for _, key := range p.keys {
+			if !p.disabled[key] {
+				enabled = append(enabled, key)
+			}
+		}
+		if len(enabled) == 0 {
+			return "", ErrNoAPIKey
+		}
+		return enabled[rand.Intn(len(enabled))], nil
+	}
+	for range p.keys {
+		key := p.keys[p.next%len(p.keys)]
+		p.next = (p.next + 1) % len(p.keys)
+		if !p.disabled[key] {
+			return key, nil
+		}
+	}
+	return "", ErrNoAPIKey
+}
@@ -0,0 +1,114 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyOf(t *testing.T, mod RequestModifier, ctx context.Context, rawurl string) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "GET", rawurl, nil)
+	require.NoError(t, err)
+	require.NoError(t, mod.Intercept(req))
+	return req.Header.Get("X-Api-Key")
+}
+
+func TestAPIKeyPool_RoundRobin(t *testing.T) {
+	pool := APIKeyPool("X-Api-Key", []string{"a", "b", "c"}, RoundRobin)
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, keyOf(t, pool, context.Background(), "http://example.com/"))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, got)
+
+	// concurrent requests share the keys evenly
+	counts := make(map[string]int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://example.com/", nil)
+			assert.NoError(t, pool.Intercept(req))
+			mu.Lock()
+			counts[req.Header.Get("X-Api-Key")]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"a": 10, "b": 10, "c": 10}, counts)
+}
+
+func TestAPIKeyPool_Disabled(t *testing.T) {
+	for _, strategy := range []Strategy{RoundRobin, Random} {
+		pool := APIKeyPool("X-Api-Key", []string{"a", "b", "c"}, strategy)
+		pool.Disable("b")
+		for i := 0; i < 20; i++ {
+			assert.NotEqual(t, "b", keyOf(t, pool, context.Background(), "http://example.com/"))
+		}
+
+		pool.Disable("a")
+		pool.Disable("c")
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		require.NoError(t, err)
+		assert.Equal(t, ErrNoAPIKey, pool.Intercept(req))
+
+		pool.Enable("b")
+		assert.Equal(t, "b", keyOf(t, pool, context.Background(), "http://example.com/"))
+	}
+}
+
+func TestAPIKeyPool_DisableOn(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") == "a" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	pool := APIKeyPool("X-Api-Key", []string{"a", "b"}, RoundRobin)
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithRequestModifier(pool),
+		WithResponseModifier(pool.DisableOn(func(res *http.Response) bool {
+			return res.StatusCode == http.StatusTooManyRequests
+		})),
+	)
+
+	var statuses []int
+	for i := 0; i < 4; i++ {
+		resp, err := c.Get(s.URL)
+		require.NoError(t, err)
+		discardResponse(resp)
+		statuses = append(statuses, resp.StatusCode)
+	}
+	assert.Equal(t, []int{http.StatusTooManyRequests, http.StatusOK, http.StatusOK, http.StatusOK}, statuses)
+}
+
+func TestAPIKeyPool_Sticky(t *testing.T) {
+	pool := APIKeyPool("X-Api-Key", []string{"a", "b", "c"}, RoundRobin)
+	ctx := WithStickyAPIKeys(context.Background())
+
+	assert.Equal(t, "a", keyOf(t, pool, ctx, "http://one.example.com/"))
+	assert.Equal(t, "b", keyOf(t, pool, ctx, "http://two.example.com/"))
+	assert.Equal(t, "a", keyOf(t, pool, ctx, "http://one.example.com/x"))
+	assert.Equal(t, "b", keyOf(t, pool, ctx, "http://two.example.com/y"))
+	// other contexts still rotate
+	assert.Equal(t, "c", keyOf(t, pool, context.Background(), "http://one.example.com/"))
+
+	// a disabled key is replaced
+	pool.Disable("a")
+	assert.Equal(t, "b", keyOf(t, pool, ctx, "http://one.example.com/"))
+	assert.Equal(t, "b", keyOf(t, pool, ctx, "http://one.example.com/"))
+}
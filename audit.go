@@ -0,0 +1,87 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultAuditTimeout is how long AuditBody waits for the sink by default
+const DefaultAuditTimeout = 5 * time.Second
+
+// ErrAuditTimeout is returned by AuditBody when the sink did not take the body
+// in time
+var ErrAuditTimeout = errors.New("audit sink timed out")
+
+// AuditOptions configures AuditBodyWith
+type AuditOptions struct {
+	// Timeout bounds the time a request waits for the sink, including the
+	// writes of the requests before it, DefaultAuditTimeout by default
+	Timeout time.Duration
+	// MaxBodySize bounds the body buffered, DefaultMaxBodySize by default
+	MaxBodySize int64
+}
+
+// AuditBody returns a RequestModifier writing the body of the requests
+// matching pred (every request if nil) to sink, with the default options of
+// AuditBodyWith
+func AuditBody(sink io.Writer, pred func(*http.Request) bool) RequestModifier {
+	return AuditBodyWith(sink, pred, AuditOptions{})
+}
+
+// AuditBodyWith returns a RequestModifier writing the body of the requests
+// matching pred (every request if nil) to sink, e.g. to archive them. The body
+// is buffered and restored so the same bytes are sent upstream, and written
+// with a single call to sink.Write, one body at a time. A request the sink
+// does not take within opts.Timeout fails with ErrAuditTimeout, and a failed
+// write fails the request, so no body is sent without being archived: the
+// body of a timed out request may still be written once the sink is done with
+// the previous one. Requests without a body are left untouched
+func AuditBodyWith(sink io.Writer, pred func(*http.Request) bool, opts AuditOptions) RequestModifier {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultAuditTimeout
+	}
+	maxBody := opts.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodySize
+	}
+	// held while a body is written, a write outliving its request keeps it
+	sem := make(chan struct{}, 1)
+
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody || (pred != nil && !pred(req)) {
+			return nil
+		}
+		body, err := ReadAndRestoreBody(req, maxBody)
+		if err != nil {
+			return errors.Wrap(err, "unable to audit request body")
+		}
+
+		ctx := req.Context()
+		expired := clockFrom(ctx).After(timeout)
+		select {
+		case sem <- struct{}{}:
+		case <-expired:
+			return ErrAuditTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		written := make(chan error, 1)
+		go func() {
+			_, err := sink.Write(body)
+			<-sem
+			written <- err
+		}()
+		select {
+		case err = <-written:
+			return errors.Wrap(err, "unable to audit request body")
+		case <-expired:
+			return ErrAuditTimeout
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
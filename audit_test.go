@@ -0,0 +1,101 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditBody(t *testing.T) {
+	var received []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var sink bytes.Buffer
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, AuditBody(&sink, func(req *http.Request) bool {
+		return strings.HasPrefix(req.URL.Path, "/payments")
+	}))
+
+	post := func(path string, body io.Reader) {
+		resp, err := c.Post(s.URL+path, "application/json", body)
+		require.NoError(t, err)
+		discardResponse(resp)
+	}
+	// a streamed body without GetBody
+	post("/payments", io.NopCloser(strings.NewReader(`{"amount":1}`)))
+	post("/payments/2", strings.NewReader(`{"amount":2}`))
+	post("/users", strings.NewReader(`{"name":"bob"}`))
+	resp, err := c.Get(s.URL + "/payments")
+	require.NoError(t, err)
+	discardResponse(resp)
+
+	assert.Equal(t, `{"amount":1}{"amount":2}`, sink.String())
+	assert.Equal(t, []string{`{"amount":1}`, `{"amount":2}`, `{"name":"bob"}`, ""}, received)
+}
+
+// blockingWriter blocks every write until release is closed
+type blockingWriter struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.buf.Write(p)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestAuditBody_Timeout(t *testing.T) {
+	sink := &blockingWriter{release: make(chan struct{})}
+	var sent int
+	k := NewRequestInterceptor(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}), AuditBodyWith(sink, nil, AuditOptions{Timeout: 20 * time.Millisecond}))
+
+	post := func(body string) error {
+		req, err := http.NewRequest("POST", "http://example.com/", strings.NewReader(body))
+		require.NoError(t, err)
+		_, err = k.RoundTrip(req)
+		return err
+	}
+
+	// the blocked write fails the request, then holds the sink for the next one
+	start := time.Now()
+	assert.True(t, errors.Is(post("a"), ErrAuditTimeout))
+	assert.True(t, errors.Is(post("b"), ErrAuditTimeout))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Zero(t, sent)
+
+	close(sink.release)
+	require.NoError(t, post("c"))
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "ac", sink.buf.String())
+
+	// a failed write fails the request
+	k = NewRequestInterceptor(k.Base, AuditBody(failingWriter{}, nil))
+	err := post("d")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+	assert.Equal(t, 1, sent)
+}
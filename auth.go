@@ -0,0 +1,60 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidUsername is returned by BasicAuth when the username contains a
+// colon, which RFC 7617 forbids since it separates the username from the
+// password
+var ErrInvalidUsername = errors.New("basic auth username must not contain a colon")
+
+// BearerToken returns a RequestModifier setting the Authorization header to
+// "Bearer <token>". An empty token leaves the request untouched
+func BearerToken(token string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		setBearer(req, token)
+		return nil
+	})
+}
+
+// BearerTokenFunc returns a RequestModifier fetching the token with fn on
+// every request, so expiring tokens can be refreshed. The request context is
+// passed to fn
+func BearerTokenFunc(fn func(ctx context.Context) (string, error)) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		token, err := fn(req.Context())
+		if err != nil {
+			return errors.Wrap(err, "unable to fetch bearer token")
+		}
+		setBearer(req, token)
+		return nil
+	})
+}
+
+func setBearer(req *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// BasicAuth returns a RequestModifier setting the HTTP Basic credentials on
+// every request, replacing any existing Authorization header. Credentials are
+// sent as UTF-8. A password containing colons is sent as is, as servers split
+// the credentials on the first colon. A username containing one cannot be told
+// apart from the password, RFC 7617 forbids it: the requests fail with
+// ErrInvalidUsername rather than authenticate as another user
+func BasicAuth(username, password string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if strings.Contains(username, ":") {
+			return ErrInvalidUsername
+		}
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
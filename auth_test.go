@@ -0,0 +1,111 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerToken(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, BearerToken("t0k3n").Intercept(req))
+	assert.Equal(t, "Bearer t0k3n", req.Header.Get("Authorization"))
+}
+
+func TestBearerToken_Empty(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Basic abc")
+
+	require.NoError(t, BearerToken("").Intercept(req))
+	assert.Equal(t, "Basic abc", req.Header.Get("Authorization"))
+}
+
+func TestBearerTokenFunc(t *testing.T) {
+	type ctxKey struct{}
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "from-context"))
+
+	mod := BearerTokenFunc(func(ctx context.Context) (string, error) {
+		return ctx.Value(ctxKey{}).(string), nil
+	})
+
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "Bearer from-context", req.Header.Get("Authorization"))
+}
+
+func TestBearerTokenFunc_Error(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	failure := errors.New("token endpoint down")
+	mod := BearerTokenFunc(func(ctx context.Context) (string, error) {
+		return "", failure
+	})
+
+	err = mod.Intercept(req)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, failure))
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
+
+func TestBasicAuth(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "jérôme" || pass != "p:a:ss" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, BasicAuth("jérôme", "p:a:ss"))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestBasicAuth_Replace(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer old")
+
+	require.NoError(t, BasicAuth("user", "pass").Intercept(req))
+	assert.Equal(t, "Basic dXNlcjpwYXNz", req.Header.Get("Authorization"))
+}
+
+func TestBasicAuth_PasswordColons(t *testing.T) {
+	for _, password := range []string{":", "a:b", ":leading", "trailing:", "a::b"} {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		require.NoError(t, err)
+		require.NoError(t, BasicAuth("user", password).Intercept(req))
+
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok, password)
+		assert.Equal(t, "user", user, password)
+		assert.Equal(t, password, pass)
+	}
+}
+
+func TestBasicAuth_InvalidUsername(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	err = BasicAuth("us:er", "pass").Intercept(req)
+	assert.True(t, errors.Is(err, ErrInvalidUsername))
+}
@@ -0,0 +1,197 @@
+package port
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AWSUnsignedPayload is the X-Amz-Content-Sha256 value asking SignAWSV4 not to
+// hash the body, so a streamed upload is not buffered. Only some services,
+// e.g. S3, accept it
+const AWSUnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Credentials are AWS access keys. SessionToken is only set for temporary
+// credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsUnsignedHeaders are never covered by the signature, proxies and
+// transports being allowed to change them
+var awsUnsignedHeaders = map[string]bool{
+	"authorization":     true,
+	"connection":        true,
+	"expect":            true,
+	"transfer-encoding": true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+}
+
+// SignAWSV4 returns a RequestModifier signing requests with AWS Signature
+// Version 4 for the given region and service, setting the Authorization and
+// X-Amz-Date headers, and X-Amz-Security-Token for temporary credentials.
+// Every header of the request is signed, along with Host. The body is
+// buffered, up to DefaultMaxBodySize, to be hashed and restored for the
+// transport, unless X-Amz-Content-Sha256 is set to AWSUnsignedPayload. For
+// S3, X-Amz-Content-Sha256 is set to the body hash and the path is escaped
+// once instead of twice
+func SignAWSV4(creds Credentials, region, service string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash != AWSUnsignedPayload {
+			body, err := ReadAndRestoreBody(req, DefaultMaxBodySize)
+			if err != nil {
+				return errors.Wrap(err, "unable to sign request")
+			}
+			payloadHash = hexSHA256(body)
+			if service == "s3" {
+				req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+			}
+		}
+
+		now := clockFrom(req.Context()).Now().UTC()
+		amzDate := now.Format("20060102T150405Z")
+		req.Header.Set("X-Amz-Date", amzDate)
+		if creds.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+
+		query, err := awsCanonicalQuery(req.URL.RawQuery)
+		if err != nil {
+			return errors.Wrap(err, "unable to sign request")
+		}
+		headers, signedHeaders := awsCanonicalHeaders(req)
+		canonicalRequest := strings.Join([]string{
+			req.Method,
+			awsCanonicalPath(req.URL, service),
+			query,
+			headers,
+			signedHeaders,
+			payloadHash,
+		}, "\n")
+
+		scope := now.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+		stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+		key := awsSigningKey(creds.SecretAccessKey, now.Format("20060102"), region, service)
+		signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+		req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+			", SignedHeaders="+signedHeaders+", Signature="+signature)
+		return nil
+	})
+}
+
+// awsSigningKey derives the key signing the requests of a day, region and
+// service from the secret access key
+func awsSigningKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+// awsCanonicalPath returns the escaped path of u, escaped a second time for
+// every service but S3
+func awsCanonicalPath(u *url.URL, service string) string {
+	var path string
+	if service == "s3" {
+		path = awsEscape(u.Path, false)
+	} else {
+		path = awsEscape(u.EscapedPath(), false)
+	}
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// awsCanonicalQuery returns the escaped query parameters sorted by name, then
+// by value
+func awsCanonicalQuery(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return "", err
+	}
+	var params []string
+	for k, vs := range values {
+		for _, v := range vs {
+			params = append(params, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&"), nil
+}
+
+// awsCanonicalHeaders returns the canonical headers block, one lowercased
+// "name:value" line per header, and the list of signed header names
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		name := strings.ToLower(k)
+		if awsUnsignedHeaders[name] || name == "host" {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
+
+// awsEscape percent-encodes every byte of s but the unreserved characters,
+// and the slashes unless encodeSlash is set
+func awsEscape(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,191 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxBodySize is the maximum number of bytes buffered in memory when a
+// request body has to be read before being sent
+const DefaultMaxBodySize = 10 << 20
+
+// ErrBodyTooLarge is returned when a request body exceeds the allowed size
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// ErrResponseTooLarge is returned when reading a response body past
+// RequestIntercepter.MaxResponseBodySize
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ReadAndRestoreBody reads the whole request body, up to max bytes, then
+// replaces req.Body, req.GetBody and req.ContentLength so the buffered bytes
+// can be read again, by the transport or a retry. It is meant for modifiers
+// needing the body, e.g. to sign or hash it. A request without a body returns
+// nil. A body larger than max fails with ErrBodyTooLarge, it is consumed and
+// closed either way
+func ReadAndRestoreBody(req *http.Request, max int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(io.LimitReader(req.Body, max+1))
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read request body")
+	}
+	if int64(len(b)) > max {
+		return nil, ErrBodyTooLarge
+	}
+	setBody(req, b)
+	return b, nil
+}
+
+// MaxBodySize returns a RequestModifier rejecting request bodies larger than
+// limit bytes with ErrBodyTooLarge. A known ContentLength is checked before
+// sending, a body of unknown length fails once more than limit bytes are read
+// from it
+func MaxBodySize(limit int64) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody {
+			return nil
+		}
+		if req.ContentLength > limit {
+			return errors.Wrapf(ErrBodyTooLarge, "%d bytes, limit is %d", req.ContentLength, limit)
+		}
+		if req.ContentLength > 0 {
+			// the transport makes sure the body matches its length
+			return nil
+		}
+		req.Body = &limitedBody{ReadCloser: req.Body, remaining: limit}
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return &limitedBody{ReadCloser: body, remaining: limit}, nil
+			}
+		}
+		return nil
+	})
+}
+
+// limitedBody fails with ErrBodyTooLarge, or tooLarge if set, once more than
+// remaining bytes are read from ReadCloser
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	tooLarge  error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err()
+	}
+	if int64(len(p)) > b.remaining+1 {
+		// one extra byte tells whether the limit is passed
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, b.err()
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) err() error {
+	if b.tooLarge != nil {
+		return b.tooLarge
+	}
+	return ErrBodyTooLarge
+}
+
+// FixContentLength returns a RequestModifier setting the exact length of a
+// replayable request body, once previous modifiers changed its size. The body,
+// buffered in memory up to DefaultMaxBodySize, stays readable and the request
+// is no longer sent chunked. Bodies without GetBody are left as is
+func FixContentLength() RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+			return nil
+		}
+		b, err := ReadAndRestoreBody(req, DefaultMaxBodySize)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			req.Body = http.NoBody
+		}
+		req.TransferEncoding = nil
+		req.Header.Del("Transfer-Encoding")
+		req.Header.Del("Content-Length")
+		return nil
+	})
+}
+
+// TransformBody returns a RequestModifier streaming the request body through
+// the reader returned by fn, e.g. to encrypt an upload without buffering it.
+// The length of the transformed body is unknown, so the request is sent
+// chunked. GetBody, when set, transforms a fresh copy of the body. Readers
+// returned by fn implementing io.Closer are closed with the body
+func TransformBody(fn func(r io.Reader) io.Reader) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody {
+			return nil
+		}
+		req.Body = transformBody(req.Body, fn)
+		req.ContentLength = -1
+		req.Header.Del("Content-Length")
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return transformBody(body, fn), nil
+			}
+		}
+		return nil
+	})
+}
+
+func transformBody(body io.ReadCloser, fn func(r io.Reader) io.Reader) io.ReadCloser {
+	r := fn(body)
+	c, ok := r.(io.Closer)
+	if !ok {
+		return &multiReadCloser{Reader: r, Closer: body}
+	}
+	return &multiReadCloser{Reader: r, Closer: closerFunc(func() error {
+		err := c.Close()
+		if berr := body.Close(); err == nil {
+			err = berr
+		}
+		return err
+	})}
+}
+
+// closerFunc is used to transform a simple function as an io.Closer
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// setBody replaces the request body with b, keeping it replayable
+func setBody(req *http.Request, b []byte) {
+	req.ContentLength = int64(len(b))
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+// multiReadCloser reads from Reader and closes Closer
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
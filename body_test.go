@@ -0,0 +1,250 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxBodySize_ContentLength(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, MaxBodySize(8))
+
+	_, err := c.Post(s.URL, "text/plain", strings.NewReader("way more than eight bytes"))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("eight b!"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestMaxBodySize_Streamed(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("0123456789")))
+	require.NoError(t, err)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("0123456789")), nil
+	}
+	require.NoError(t, MaxBodySize(4).Intercept(req))
+
+	b, err := io.ReadAll(req.Body)
+	assert.Equal(t, ErrBodyTooLarge, err)
+	assert.Equal(t, "0123", string(b))
+
+	// replayed bodies are limited as well
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	_, err = io.ReadAll(body)
+	assert.Equal(t, ErrBodyTooLarge, err)
+
+	req, err = http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("0123")))
+	require.NoError(t, err)
+	require.NoError(t, MaxBodySize(4).Intercept(req))
+	b, err = io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "0123", string(b))
+}
+
+func TestMaxBodySize_StreamedRoundTrip(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	// the body is too large to be buffered, so its length stays unknown
+	c.Transport = NewInterceptor(c.Transport, WithMaxBodySize(2), WithRequestModifier(MaxBodySize(8)))
+
+	_, err := c.Post(s.URL, "text/plain", io.NopCloser(strings.NewReader(strings.Repeat("x", 64))))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBodyTooLarge))
+}
+
+// upperReader uppercases ASCII letters, recording whether it was closed
+type upperReader struct {
+	r      io.Reader
+	closed bool
+}
+
+func (u *upperReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	copy(p[:n], bytes.ToUpper(p[:n]))
+	return n, err
+}
+
+func (u *upperReader) Close() error {
+	u.closed = true
+	return nil
+}
+
+func TestTransformBody(t *testing.T) {
+	type received struct {
+		body          string
+		contentLength int64
+		chunked       bool
+	}
+	var got received
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = received{string(b), r.ContentLength, len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var readers []*upperReader
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, TransformBody(func(r io.Reader) io.Reader {
+		u := &upperReader{r: r}
+		readers = append(readers, u)
+		return u
+	}))
+
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader("hello world"))
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, received{"HELLO WORLD", -1, true}, got)
+	require.NotEmpty(t, readers)
+	assert.True(t, readers[0].closed)
+}
+
+func TestTransformBody_GetBody(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader("abc"))
+	require.NoError(t, err)
+	require.NoError(t, TransformBody(func(r io.Reader) io.Reader { return &upperReader{r: r} }).Intercept(req))
+
+	assert.Equal(t, int64(-1), req.ContentLength)
+	require.NotNil(t, req.GetBody)
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	b, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "ABC", string(b))
+
+	// without GetBody, the body cannot be replayed
+	req, err = http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("abc")))
+	require.NoError(t, err)
+	require.NoError(t, TransformBody(func(r io.Reader) io.Reader { return r }).Intercept(req))
+	assert.Nil(t, req.GetBody)
+}
+
+func TestFixContentLength(t *testing.T) {
+	type received struct {
+		body          string
+		contentLength int64
+		chunked       bool
+	}
+	var got received
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = received{string(b), r.ContentLength, len(r.TransferEncoding) > 0}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	// the first modifier grows the body, making its length unknown
+	grow := TransformBody(func(r io.Reader) io.Reader { return io.MultiReader(r, strings.NewReader("!!")) })
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, ChainModifiers(grow, FixContentLength()))
+
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader("hello world"))
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, received{"hello world!!", 13, false}, got)
+}
+
+func TestFixContentLength_Stale(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader("abc"))
+	require.NoError(t, err)
+	// a modifier replaced the body but left the previous length behind
+	setBody(req, []byte("abcdef"))
+	req.ContentLength = 3
+	req.Header.Set("Content-Length", "3")
+	req.TransferEncoding = []string{"chunked"}
+
+	require.NoError(t, FixContentLength().Intercept(req))
+	assert.Equal(t, int64(6), req.ContentLength)
+	assert.Empty(t, req.TransferEncoding)
+	assert.Empty(t, req.Header.Get("Content-Length"))
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(b))
+	body, err := req.GetBody()
+	require.NoError(t, err)
+	b, err = io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", string(b))
+
+	// without GetBody the request is left untouched
+	req, err = http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("abc")))
+	require.NoError(t, err)
+	require.NoError(t, FixContentLength().Intercept(req))
+	assert.Equal(t, int64(0), req.ContentLength)
+}
+
+func TestReadAndRestoreBody(t *testing.T) {
+	// no body
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	require.NoError(t, err)
+	b, err := ReadAndRestoreBody(req, 10)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+	assert.Nil(t, req.Body)
+
+	// a streamed body within the limit can be read again
+	req, err = http.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("hello")))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+	b, err = ReadAndRestoreBody(req, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	assert.Equal(t, int64(5), req.ContentLength)
+	for i := 0; i < 2; i++ {
+		body, err := req.GetBody()
+		require.NoError(t, err)
+		got, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(got))
+	}
+	got, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+
+	// a body over the limit
+	req, err = http.NewRequest("POST", "http://example.com/", strings.NewReader("hello!"))
+	require.NoError(t, err)
+	b, err = ReadAndRestoreBody(req, 5)
+	assert.Equal(t, ErrBodyTooLarge, err)
+	assert.Nil(t, b)
+}
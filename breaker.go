@@ -0,0 +1,252 @@
+package port
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker, without reaching the network,
+// while the circuit of the request host is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit of a host
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests with ErrCircuitOpen
+	CircuitOpen
+	// CircuitHalfOpen lets probes through
+	CircuitHalfOpen
+)
+
+// String returns the name of the state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerState is a snapshot of the circuit of a host
+type BreakerState struct {
+	State CircuitState
+	// Failures is the number of consecutive failures, reset by a success
+	Failures int
+	// LastTrip is the last time the circuit opened, zero if it never did
+	LastTrip time.Time
+}
+
+// circuit is the state of the breaker for a single host
+type circuit struct {
+	state     CircuitState
+	failures  int       // consecutive failures
+	openedAt  time.Time // last time the circuit opened
+	probes    int       // probes let through while half-open
+	successes int       // successful probes while half-open
+}
+
+// NewCircuitBreaker returns a roundtripper opening the circuit of a host after
+// threshold consecutive failures, and letting probes through again once
+// openTimeout has elapsed
+func NewCircuitBreaker(baseTransport http.RoundTripper, threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Base:             baseTransport,
+		FailureThreshold: threshold,
+		OpenTimeout:      openTimeout,
+	}
+}
+
+// CircuitBreaker stops sending requests to a host that keeps failing.
+// Connection errors and 5xx responses count as failures. Once
+// FailureThreshold consecutive failures are reached, the circuit opens and
+// requests fail fast with ErrCircuitOpen. After OpenTimeout the circuit is
+// half-open: up to HalfOpenProbes requests are let through, closing the
+// circuit if they all succeed or opening it again on the first failure
+type CircuitBreaker struct {
+	Base http.RoundTripper
+	// FailureThreshold is the number of consecutive failures opening the
+	// circuit, 5 by default
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before probing, 30s by
+	// default
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of successful probes needed to close the
+	// circuit, 1 by default
+	HalfOpenProbes int
+
+	mu       sync.Mutex // guards circuits
+	circuits map[string]*circuit
+}
+
+// RoundTrip sends the request unless the circuit of its host is open
+func (b *CircuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	clk := clockFrom(req.Context())
+	if err := b.acquire(host, clk.Now()); err != nil {
+		closeBody(req)
+		return nil, errors.Wrap(err, host)
+	}
+
+	res, err := b.base().RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// the caller gave up, it says nothing about the host health
+		b.release(host)
+		return res, err
+	}
+	b.record(host, err == nil && res.StatusCode < http.StatusInternalServerError, clk.Now())
+	return res, err
+}
+
+// acquire checks whether a request can be sent to host
+func (b *CircuitBreaker) acquire(host string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < b.openTimeout() {
+			return ErrCircuitOpen
+		}
+		c.state = CircuitHalfOpen
+		c.probes = 0
+		c.successes = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.halfOpenProbes() {
+			return ErrCircuitOpen
+		}
+		c.probes++
+	}
+	return nil
+}
+
+// release gives back a probe slot without recording any outcome
+func (b *CircuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	if c.state == CircuitHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+}
+
+// record updates the circuit of host with the outcome of a request
+func (b *CircuitBreaker) record(host string, success bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.circuit(host)
+	switch c.state {
+	case CircuitClosed:
+		if success {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.failureThreshold() {
+			b.trip(c, now)
+		}
+	case CircuitHalfOpen:
+		if !success {
+			c.failures++
+			b.trip(c, now)
+			return
+		}
+		c.successes++
+		if c.successes >= b.halfOpenProbes() {
+			*c = circuit{openedAt: c.openedAt}
+		}
+	}
+}
+
+func (b *CircuitBreaker) trip(c *circuit, now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+	c.probes = 0
+	c.successes = 0
+}
+
+// State returns the state of the circuit of host, closed for a host never
+// requested. An open circuit turns half-open on the first request sent after
+// OpenTimeout
+func (b *CircuitBreaker) State(host string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.circuits[host]
+	if !ok {
+		return BreakerState{}
+	}
+	return c.snapshot()
+}
+
+// States returns a copy of the state of the circuit of every host requested
+// so far, e.g. to be exposed by a health endpoint
+func (b *CircuitBreaker) States() map[string]BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	states := make(map[string]BreakerState, len(b.circuits))
+	for host, c := range b.circuits {
+		states[host] = c.snapshot()
+	}
+	return states
+}
+
+func (c *circuit) snapshot() BreakerState {
+	return BreakerState{State: c.state, Failures: c.failures, LastTrip: c.openedAt}
+}
+
+// circuit returns the circuit of host, b.mu must be held
+func (b *CircuitBreaker) circuit(host string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{}
+		b.circuits[host] = c
+	}
+	return c
+}
+
+func (b *CircuitBreaker) failureThreshold() int {
+	if b.FailureThreshold > 0 {
+		return b.FailureThreshold
+	}
+	return 5
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout > 0 {
+		return b.OpenTimeout
+	}
+	return 30 * time.Second
+}
+
+func (b *CircuitBreaker) halfOpenProbes() int {
+	if b.HalfOpenProbes > 0 {
+		return b.HalfOpenProbes
+	}
+	return 1
+}
+
+// Wrap sets base as the Base of CircuitBreaker, so it can be used with Stack
+func (b *CircuitBreaker) Wrap(base http.RoundTripper) http.RoundTripper {
+	b.Base = base
+	return b
+}
+
+func (b *CircuitBreaker) base() http.RoundTripper {
+	if b.Base != nil {
+		return b.Base
+	}
+	return http.DefaultTransport
+}
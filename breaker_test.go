@@ -0,0 +1,198 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var healthy int32
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewCircuitBreaker(c.Transport, 3, 100*time.Millisecond)
+
+	get := func() (*http.Response, error) {
+		resp, err := c.Get(s.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// trip the breaker
+	for i := 0; i < 3; i++ {
+		resp, err := get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	// fail fast without reaching the server
+	_, err := get()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// a failing probe opens it again
+	time.Sleep(150 * time.Millisecond)
+	_, err = get()
+	require.NoError(t, err)
+	_, err = get()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// recovery
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		resp, err := get()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestCircuitBreaker_PerHost(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer working.Close()
+
+	c := &http.Client{Transport: NewCircuitBreaker(nil, 1, time.Hour)}
+
+	resp, err := c.Get(failing.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	_, err = c.Get(failing.URL)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	resp, err = c.Get(working.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCircuitBreaker_ConnectionError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := s.URL
+	s.Close()
+
+	c := &http.Client{Transport: NewCircuitBreaker(nil, 2, time.Hour)}
+	for i := 0; i < 2; i++ {
+		_, err := c.Get(url)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	_, err := c.Get(url)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_States(t *testing.T) {
+	var healthy int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	clk := newFakeClock()
+	b := NewCircuitBreaker(s.Client().Transport, 2, time.Minute)
+	c := &http.Client{Transport: b}
+	get := func() {
+		req, err := http.NewRequestWithContext(withClock(context.Background(), clk), "GET", s.URL, nil)
+		require.NoError(t, err)
+		if resp, err := c.Do(req); err == nil {
+			discardResponse(resp)
+		}
+	}
+	host := strings.TrimPrefix(s.URL, "http://")
+
+	assert.Equal(t, BreakerState{}, b.State(host))
+	assert.Empty(t, b.States())
+
+	get()
+	assert.Equal(t, BreakerState{State: CircuitClosed, Failures: 1}, b.State(host))
+
+	// tripped
+	get()
+	tripped := clk.Now()
+	assert.Equal(t, BreakerState{State: CircuitOpen, Failures: 2, LastTrip: tripped}, b.State(host))
+	assert.Equal(t, "open", b.State(host).State.String())
+
+	// the snapshot is a copy
+	states := b.States()
+	assert.Equal(t, map[string]BreakerState{host: {State: CircuitOpen, Failures: 2, LastTrip: tripped}}, states)
+	states[host] = BreakerState{}
+	assert.Equal(t, CircuitOpen, b.State(host).State)
+
+	// a failing probe trips it again
+	clk.Advance(time.Minute)
+	get()
+	assert.Equal(t, BreakerState{State: CircuitOpen, Failures: 3, LastTrip: clk.Now()}, b.State(host))
+
+	// a successful probe closes it
+	atomic.StoreInt32(&healthy, 1)
+	clk.Advance(time.Minute)
+	get()
+	assert.Equal(t, BreakerState{State: CircuitClosed, LastTrip: clk.Now().Add(-time.Minute)}, b.State(host))
+	assert.Equal(t, "closed", b.State(host).State.String())
+	assert.Equal(t, BreakerState{}, b.State("unknown.example.com"))
+}
+
+func TestCircuitBreaker_HalfOpenState(t *testing.T) {
+	release := make(chan struct{})
+	b := NewCircuitBreaker(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("probe") != "" {
+			<-release
+		}
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody, Request: req}, nil
+	}), 1, time.Minute)
+
+	clk := newFakeClock()
+	req, err := http.NewRequestWithContext(withClock(context.Background(), clk), "GET", "http://example.com/", nil)
+	require.NoError(t, err)
+	_, err = b.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, CircuitOpen, b.State("example.com").State)
+
+	clk.Advance(time.Minute)
+	probe := req.Clone(req.Context())
+	probe.Header.Set("probe", "1")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = b.RoundTrip(probe)
+	}()
+	assert.Eventually(t, func() bool {
+		return b.State("example.com").State == CircuitHalfOpen
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "half-open", b.State("example.com").State.String())
+	close(release)
+	<-done
+	assert.Equal(t, CircuitOpen, b.State("example.com").State)
+}
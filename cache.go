@@ -0,0 +1,262 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response stored by CacheTransport
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Expires is the end of the freshness lifetime, the response has to be
+	// revalidated afterwards. A zero value means it must always be revalidated
+	Expires time.Time
+}
+
+// Cache stores the responses of CacheTransport. Implementations must be safe
+// for concurrent use
+type Cache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+// NewMemoryCache returns an empty in-memory Cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]*CachedResponse)}
+}
+
+// MemoryCache is a Cache keeping responses in a map, without eviction
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]*CachedResponse
+}
+
+// Get returns the response stored under key
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+// Set stores entry under key
+func (c *MemoryCache) Set(key string, entry *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+// Delete removes the response stored under key
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// NewCacheTransport returns a roundtripper caching GET responses in cache
+func NewCacheTransport(baseTransport http.RoundTripper, cache Cache) *CacheTransport {
+	return &CacheTransport{
+		Base:  baseTransport,
+		Cache: cache,
+	}
+}
+
+// CacheTransport is a private HTTP cache for GET requests. Freshness comes
+// from the Cache-Control max-age directive or the Expires header. Stale
+// responses are revalidated with If-None-Match and If-Modified-Since when they
+// carry an ETag or a Last-Modified header, a 304 serves the stored body.
+// no-store responses and requests are never cached, no-cache forces a
+// revalidation. Bodies larger than DefaultMaxBodySize are not cached
+type CacheTransport struct {
+	Base  http.RoundTripper
+	Cache Cache
+	// Key identifies the requests sharing a cached response, DefaultKey by
+	// default
+	Key KeyFunc
+}
+
+// RoundTrip serves the request from the cache when possible
+func (t *CacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Cache == nil || (req.Method != "" && req.Method != http.MethodGet) {
+		return t.base().RoundTrip(req)
+	}
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.base().RoundTrip(req)
+	}
+	req = keyRequest(req)
+	key, err := t.key(req)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	clk := clockFrom(req.Context())
+	entry, cached := t.Cache.Get(key)
+	_, noCache := reqCC["no-cache"]
+	if cached && !noCache && clk.Now().Before(entry.Expires) {
+		return entry.response(req), nil
+	}
+
+	outgoing := req
+	if cached {
+		outgoing = cloneRequest(req)
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.Header.Get("Last-Modified"); lm != "" {
+			outgoing.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	res, err := t.base().RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && res.StatusCode == http.StatusNotModified {
+		discardResponse(res)
+		refreshed := &CachedResponse{
+			StatusCode: entry.StatusCode,
+			Header:     entry.Header.Clone(),
+			Body:       entry.Body,
+		}
+		for k, v := range res.Header {
+			refreshed.Header[k] = v
+		}
+		refreshed.Expires = freshness(refreshed.Header, clk.Now())
+		t.Cache.Set(key, refreshed)
+		return refreshed.response(req), nil
+	}
+
+	if !cacheable(res) {
+		if cached {
+			t.Cache.Delete(key)
+		}
+		return res, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, DefaultMaxBodySize+1))
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	if len(body) > DefaultMaxBodySize {
+		// too large to be cached, hand the whole body to the caller
+		res.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		return res, nil
+	}
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.Cache.Set(key, &CachedResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header.Clone(),
+		Body:       body,
+		Expires:    freshness(res.Header, clk.Now()),
+	})
+	return res, nil
+}
+
+// Wrap sets base as the Base of CacheTransport, so it can be used with Stack
+func (t *CacheTransport) Wrap(base http.RoundTripper) http.RoundTripper {
+	t.Base = base
+	return t
+}
+
+func (t *CacheTransport) key(req *http.Request) (string, error) {
+	if t.Key != nil {
+		return t.Key(req)
+	}
+	return DefaultKey(req)
+}
+
+func (t *CacheTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// response builds a response for req out of the cached entry
+func (e *CachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// cacheable reports whether res can be stored
+func cacheable(res *http.Response) bool {
+	if res.StatusCode != http.StatusOK {
+		return false
+	}
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if _, ok := cc["max-age"]; ok {
+		return true
+	}
+	return res.Header.Get("Expires") != "" || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+}
+
+// freshness returns the end of the freshness lifetime of a response received
+// at now
+func freshness(h http.Header, now time.Time) time.Time {
+	cc := parseCacheControl(h)
+	if _, ok := cc["no-cache"]; ok {
+		return time.Time{}
+	}
+	if v, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			return time.Time{}
+		}
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return time.Time{}
+		}
+		// Expires is relative to the server clock
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			return now.Add(expires.Sub(date))
+		}
+		return expires
+	}
+	return time.Time{}
+}
+
+// parseCacheControl returns the directives of the Cache-Control header,
+// lowercased, with their value if any
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return cc
+}
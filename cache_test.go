@@ -0,0 +1,156 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getBody(t *testing.T, c *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := c.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(b)
+}
+
+func TestCacheTransport_FreshHit(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("cached body"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewCacheTransport(c.Transport, NewMemoryCache())
+
+	for i := 0; i < 3; i++ {
+		status, body := getBody(t, c, s.URL)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "cached body", body)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCacheTransport_Revalidation(t *testing.T) {
+	var calls, notModified int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("original body"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewCacheTransport(c.Transport, NewMemoryCache())
+
+	for i := 0; i < 3; i++ {
+		status, body := getBody(t, c, s.URL)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "original body", body)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&notModified))
+}
+
+func TestCacheTransport_RevalidationRefreshesFreshness(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if n > 1 {
+			t.Error("the stale response should have been revalidated")
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewCacheTransport(c.Transport, NewMemoryCache())
+
+	for i := 0; i < 3; i++ {
+		_, body := getBody(t, c, s.URL)
+		assert.Equal(t, "body", body)
+	}
+	// the 304 made the entry fresh for 60s
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCacheTransport_NoStore(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+		_, _ = w.Write([]byte("secret"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	cache := NewMemoryCache()
+	c := s.Client()
+	c.Transport = NewCacheTransport(c.Transport, cache)
+
+	for i := 0; i < 2; i++ {
+		_, body := getBody(t, c, s.URL)
+		assert.Equal(t, "secret", body)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Empty(t, cache.entries)
+}
+
+func TestCacheTransport_RequestNoStore(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewCacheTransport(c.Transport, NewMemoryCache())
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", s.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Cache-Control", "no-store")
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,119 @@
+package port
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ChainModifiers returns a RequestModifier applying every given modifier in
+// order. The chain stops at the first failing modifier, nil modifiers are
+// skipped
+func ChainModifiers(mods ...RequestModifier) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for i, m := range mods {
+			if m == nil {
+				continue
+			}
+			if err := runModifier(m, req); err != nil {
+				return errors.Wrapf(err, "modifier %d failed", i)
+			}
+		}
+		return nil
+	})
+}
+
+// NamedModifier is a RequestModifier identified by a name, see
+// InstrumentedChain
+type NamedModifier struct {
+	Name     string
+	Modifier RequestModifier
+}
+
+// InstrumentedChain returns a RequestModifier applying every given modifier in
+// order, like ChainModifiers, and calling obs with the name, duration and
+// error of every modifier applied, to find out which one slows requests down
+func InstrumentedChain(obs func(name string, d time.Duration, err error), mods ...NamedModifier) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		clk := clockFrom(req.Context())
+		for _, m := range mods {
+			if m.Modifier == nil {
+				continue
+			}
+			start := clk.Now()
+			err := runModifier(m.Modifier, req)
+			if obs != nil {
+				obs(m.Name, clk.Now().Sub(start), err)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "modifier %s failed", m.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// ChainResponseModifiers returns a ResponseModifier applying every given
+// modifier in order. The chain stops at the first failing modifier and closes
+// the response body, nil modifiers are skipped
+func ChainResponseModifiers(mods ...ResponseModifier) ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		for i, m := range mods {
+			if m == nil {
+				continue
+			}
+			if err := m.ModifyResponse(resp); err != nil {
+				if resp.Body != nil {
+					_ = resp.Body.Close()
+				}
+				return errors.Wrapf(err, "response modifier %d failed", i)
+			}
+		}
+		return nil
+	})
+}
+
+// When returns a RequestModifier applying mod only to the requests matching
+// pred. The predicate sees the request being modified
+func When(pred func(req *http.Request) bool, mod RequestModifier) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if mod == nil || !pred(req) {
+			return nil
+		}
+		return runModifier(mod, req)
+	})
+}
+
+// HostIs returns a predicate matching requests sent to host. A host without
+// port matches any port
+func HostIs(host string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		return strings.EqualFold(req.URL.Host, host) || strings.EqualFold(req.URL.Hostname(), host)
+	}
+}
+
+// PathHasPrefix returns a predicate matching requests whose path starts with
+// prefix
+func PathHasPrefix(prefix string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		return strings.HasPrefix(req.URL.Path, prefix)
+	}
+}
+
+// MethodIs returns a predicate matching requests using one of methods
+func MethodIs(methods ...string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		method := req.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		for _, m := range methods {
+			if strings.EqualFold(m, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,187 @@
+package port
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendHeader(value string) RequestModifier {
+	return RequestModifierFunc(func(r *http.Request) error {
+		r.Header.Add("X-Step", value)
+		return nil
+	})
+}
+
+func TestChainModifiers(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	err = ChainModifiers(appendHeader("1"), nil, appendHeader("2"), appendHeader("3")).Intercept(req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, req.Header["X-Step"])
+}
+
+func TestChainModifiers_Empty(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, ChainModifiers().Intercept(req))
+	require.NoError(t, ChainModifiers(nil, nil).Intercept(req))
+}
+
+func TestChainModifiers_Error(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	failure := errors.New("failure")
+	err = ChainModifiers(
+		appendHeader("1"),
+		RequestModifierFunc(func(r *http.Request) error { return failure }),
+		appendHeader("3"),
+	).Intercept(req)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "modifier 1 failed")
+	assert.True(t, errors.Is(err, failure))
+	assert.Equal(t, []string{"1"}, req.Header["X-Step"])
+}
+
+func TestInstrumentedChain(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	type observation struct {
+		name string
+		d    time.Duration
+		err  error
+	}
+	var observed []observation
+	obs := func(name string, d time.Duration, err error) {
+		observed = append(observed, observation{name, d, err})
+	}
+	failure := errors.New("failure")
+	mod := InstrumentedChain(obs,
+		NamedModifier{Name: "fast", Modifier: appendHeader("1")},
+		NamedModifier{Name: "skipped"},
+		NamedModifier{Name: "slow", Modifier: RequestModifierFunc(func(r *http.Request) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})},
+		NamedModifier{Name: "failing", Modifier: RequestModifierFunc(func(r *http.Request) error { return failure })},
+		NamedModifier{Name: "never", Modifier: appendHeader("2")},
+	)
+
+	err = mod.Intercept(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "modifier failing failed")
+	assert.True(t, errors.Is(err, failure))
+
+	require.Len(t, observed, 3)
+	assert.Equal(t, "fast", observed[0].name)
+	assert.Equal(t, "slow", observed[1].name)
+	assert.Equal(t, "failing", observed[2].name)
+	assert.True(t, observed[1].d >= 20*time.Millisecond)
+	assert.True(t, observed[1].d > observed[0].d)
+	assert.NoError(t, observed[1].err)
+	assert.Equal(t, failure, observed[2].err)
+	assert.Equal(t, []string{"1"}, req.Header["X-Step"])
+}
+
+func appendResponseHeader(value string) ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		resp.Header.Add("X-Step", value)
+		return nil
+	})
+}
+
+func TestChainResponseModifiers(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}, Body: http.NoBody}
+
+	err := ChainResponseModifiers(appendResponseHeader("1"), nil, appendResponseHeader("2"), appendResponseHeader("3")).ModifyResponse(resp)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, resp.Header["X-Step"])
+	require.NoError(t, ChainResponseModifiers(nil).ModifyResponse(resp))
+}
+
+func TestChainResponseModifiers_Error(t *testing.T) {
+	body := &closeRecorder{ReadCloser: io.NopCloser(strings.NewReader("body"))}
+	resp := &http.Response{Header: http.Header{}, Body: body}
+
+	failure := errors.New("failure")
+	err := ChainResponseModifiers(
+		appendResponseHeader("1"),
+		ResponseModifierFunc(func(resp *http.Response) error { return failure }),
+		appendResponseHeader("3"),
+	).ModifyResponse(resp)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "response modifier 1 failed")
+	assert.True(t, errors.Is(err, failure))
+	assert.Equal(t, []string{"1"}, resp.Header["X-Step"])
+	assert.True(t, body.closed)
+}
+
+func TestWhen(t *testing.T) {
+	mod := ChainModifiers(
+		When(HostIs("api.example.com"), BearerToken("api-token")),
+		When(HostIs("admin.example.com:8443"), BasicAuth("admin", "pass")),
+		When(PathHasPrefix("/upload"), appendHeader("upload")),
+		When(MethodIs("post", "PUT"), appendHeader("write")),
+	)
+
+	cases := []struct {
+		method string
+		url    string
+		auth   string
+		steps  []string
+	}{
+		{"GET", "https://api.example.com/items", "Bearer api-token", nil},
+		{"GET", "https://API.example.com:443/items", "Bearer api-token", nil},
+		{"POST", "https://admin.example.com:8443/upload/file", "Basic YWRtaW46cGFzcw==", []string{"upload", "write"}},
+		{"GET", "https://admin.example.com/", "", nil},
+		{"PUT", "https://other.example.com/", "", []string{"write"}},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, c.url, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, mod.Intercept(req))
+		assert.Equal(t, c.auth, req.Header.Get("Authorization"), c.url)
+		assert.Equal(t, c.steps, req.Header["X-Step"], c.url)
+	}
+}
+
+func TestWhen_SeesClone(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Step")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	target, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	// the predicate runs on the rewritten request
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, ChainModifiers(
+		RewriteURL(target),
+		When(HostIs(target.Host), appendHeader("rewritten")),
+	))
+
+	resp, err := c.Get("http://elsewhere.example.com/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "rewritten", received)
+}
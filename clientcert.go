@@ -0,0 +1,31 @@
+package port
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// ClientCertTransport returns a clone of base, http.DefaultTransport if nil,
+// presenting cert to the servers requesting a client certificate. The other
+// TLS settings of base are kept
+func ClientCertTransport(base *http.Transport, cert tls.Certificate) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	t.TLSClientConfig.GetClientCertificate = nil
+	return t
+}
+
+// HandleClientCert registers for host a transport cloned from base presenting
+// cert, see ClientCertTransport. The client certificate is chosen when a
+// connection is established, so every certificate gets a transport, and a
+// connection pool, of its own: connections made with a certificate are never
+// reused for a host routed to another one
+func (r *HostRouter) HandleClientCert(host string, base *http.Transport, cert tls.Certificate) {
+	r.Handle(host, ClientCertTransport(base, cert))
+}
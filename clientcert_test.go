@@ -0,0 +1,74 @@
+package port
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selfSignedCert(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func mtlsServer() *httptest.Server {
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	s.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	s.StartTLS()
+	return s
+}
+
+func TestHostRouter_HandleClientCert(t *testing.T) {
+	s1, s2 := mtlsServer(), mtlsServer()
+
+	defer func() {
+		s1.Close()
+		s2.Close()
+	}()
+
+	// httptest servers share their certificate, one client trusts both
+	base := s1.Client().Transport.(*http.Transport)
+	router := NewHostRouter(base)
+	for s, cn := range map[*httptest.Server]string{s1: "client-one", s2: "client-two"} {
+		u, err := url.Parse(s.URL)
+		require.NoError(t, err)
+		router.HandleClientCert(u.Host, base, selfSignedCert(t, cn))
+	}
+	c := &http.Client{Transport: router}
+
+	for s, cn := range map[*httptest.Server]string{s1: "client-one", s2: "client-two"} {
+		resp, err := c.Get(s.URL)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, cn, string(b))
+	}
+	assert.Nil(t, base.TLSClientConfig.Certificates)
+}
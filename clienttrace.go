@@ -0,0 +1,89 @@
+package port
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Phases are the durations of the phases of a round trip, measured with
+// httptrace. The phases of connection setup are zero when an idle connection
+// was reused, or when they did not apply, e.g. DNS for an IP address
+type Phases struct {
+	// DNS is the time spent resolving the host
+	DNS time.Duration
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+	// TLS is the time spent in the TLS handshake
+	TLS time.Duration
+	// TTFB is the time from the start of the round trip to the first byte of
+	// the response
+	TTFB time.Duration
+	// Total is the time from the start of the round trip until the response
+	// headers were received, or the request failed
+	Total time.Duration
+	// Reused tells whether the request was sent over an idle connection
+	Reused bool
+}
+
+// phasesRecorder fills Phases from the httptrace hooks, which can be called
+// from several goroutines
+type phasesRecorder struct {
+	mu                                   sync.Mutex
+	clk                                  Clock
+	start, dnsStart, connStart, tlsStart time.Time
+	phases                               Phases
+}
+
+func newPhasesRecorder(clk Clock) *phasesRecorder {
+	return &phasesRecorder{clk: clk, start: clk.Now()}
+}
+
+func (p *phasesRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { p.mark(&p.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { p.since(&p.dnsStart, &p.phases.DNS) },
+		ConnectStart: func(string, string) {
+			p.mark(&p.connStart)
+		},
+		ConnectDone: func(string, string, error) {
+			p.since(&p.connStart, &p.phases.Connect)
+		},
+		TLSHandshakeStart: func() { p.mark(&p.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			p.since(&p.tlsStart, &p.phases.TLS)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.phases.Reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			p.since(&p.start, &p.phases.TTFB)
+		},
+	}
+}
+
+func (p *phasesRecorder) mark(t *time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	*t = p.clk.Now()
+}
+
+func (p *phasesRecorder) since(start *time.Time, d *time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !start.IsZero() {
+		*d = p.clk.Now().Sub(*start)
+	}
+}
+
+// done returns the phases measured so far
+func (p *phasesRecorder) done() Phases {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phases := p.phases
+	phases.Total = p.clk.Now().Sub(p.start)
+	return phases
+}
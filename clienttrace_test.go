@@ -0,0 +1,59 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClientTrace(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var phases []Phases
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithClientTrace(func(p Phases) {
+		phases = append(phases, p)
+	}))
+
+	// the trace of the caller is still called
+	var gotConn, firstByte int
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { gotConn++ },
+		GotFirstResponseByte: func() { firstByte++ },
+	})
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		discardResponse(resp)
+	}
+	assert.Equal(t, 2, gotConn)
+	assert.Equal(t, 2, firstByte)
+
+	require.Len(t, phases, 2)
+	assert.False(t, phases[0].Reused)
+	assert.Greater(t, phases[0].Connect, time.Duration(0))
+	assert.GreaterOrEqual(t, phases[0].TTFB, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, phases[0].Total, phases[0].TTFB)
+	// no lookup for an IP address
+	assert.Zero(t, phases[0].DNS)
+
+	// the idle connection is reused
+	assert.True(t, phases[1].Reused)
+	assert.Zero(t, phases[1].Connect)
+	assert.GreaterOrEqual(t, phases[1].TTFB, 10*time.Millisecond)
+}
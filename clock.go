@@ -0,0 +1,38 @@
+package port
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time to the time-dependent features: retry backoff, cache
+// freshness, token expiry, rate limits, recorded timings... It is carried by
+// the request context, so tests can replace it, see WithClock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel receiving the current time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the clock used by default, backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type clockKey struct{}
+
+// withClock returns a copy of ctx carrying c
+func withClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// clockFrom returns the clock carried by ctx, the real clock if none
+func clockFrom(ctx context.Context) Clock {
+	if c, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return c
+	}
+	return realClock{}
+}
@@ -0,0 +1,201 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock only moves forward when advanced
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the timers due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- c.now
+	}
+	c.waiters = pending
+}
+
+// fakeClockTokenSource issues tokens valid for an hour of a fake clock
+type fakeClockTokenSource struct {
+	clock *fakeClock
+	calls int32
+}
+
+func (s *fakeClockTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	n := atomic.AddInt32(&s.calls, 1)
+	return "token-" + strconv.Itoa(int(n)), s.clock.Now().Add(time.Hour), nil
+}
+
+func TestWithClock_TokenRefresh(t *testing.T) {
+	clk := newFakeClock()
+	src := &fakeClockTokenSource{clock: clk}
+	rec := &Recorder{}
+	it := NewInterceptor(rec, WithRequestModifier(OAuth2TokenSource(src)), WithClock(clk))
+
+	send := func() string {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		require.NoError(t, err)
+		resp, err := it.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		reqs := rec.Requests()
+		return reqs[len(reqs)-1].Header.Get("Authorization")
+	}
+
+	assert.Equal(t, "Bearer token-1", send())
+	clk.Advance(30 * time.Minute)
+	assert.Equal(t, "Bearer token-1", send())
+	// within the refresh delta of the expiry
+	clk.Advance(30*time.Minute - tokenExpiryDelta)
+	assert.Equal(t, "Bearer token-2", send())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&src.calls))
+}
+
+func TestWithClock_CacheExpiry(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	clk := newFakeClock()
+	c := s.Client()
+	c.Transport = NewInterceptor(NewCacheTransport(c.Transport, NewMemoryCache()), WithClock(clk))
+
+	get := func() {
+		resp, err := c.Get(s.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	get()
+	clk.Advance(59 * time.Second)
+	get()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	clk.Advance(2 * time.Second)
+	get()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestWithClock_Timeout(t *testing.T) {
+	clk := newFakeClock()
+	rec := &Recorder{}
+	var inModifier Clock
+	it := NewInterceptor(rec, WithRequestModifier(RequestModifierFunc(func(req *http.Request) error {
+		inModifier = clockFrom(req.Context())
+		return nil
+	})), WithClock(clk), WithTimeout(time.Minute))
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := it.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// the timeout context keeps the clock
+	assert.Same(t, clk, inModifier)
+	reqs := rec.Requests()
+	require.Len(t, reqs, 1)
+	assert.Same(t, clk, clockFrom(reqs[0].Context()))
+	_, ok := reqs[0].Context().Deadline()
+	assert.True(t, ok)
+}
+
+func TestWithClock_Timings(t *testing.T) {
+	clk := newFakeClock()
+	started := clk.Now()
+	var buf bytes.Buffer
+	har := NewHARRecorder(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		clk.Advance(2 * time.Second)
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: req}, nil
+	}), &buf)
+
+	var durations []time.Duration
+	chain := InstrumentedChain(func(name string, d time.Duration, err error) {
+		durations = append(durations, d)
+	}, NamedModifier{Name: "slow", Modifier: RequestModifierFunc(func(req *http.Request) error {
+		clk.Advance(time.Second)
+		return nil
+	})})
+
+	var phases Phases
+	it := NewInterceptor(har, WithRequestModifier(chain), WithClock(clk), WithClientTrace(func(p Phases) {
+		phases = p
+	}))
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := it.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.NoError(t, har.Close())
+
+	assert.Equal(t, []time.Duration{time.Second}, durations)
+	assert.Equal(t, 2*time.Second, phases.Total)
+	var log struct {
+		Log struct {
+			Entries []struct {
+				StartedDateTime time.Time
+				Time            float64
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Len(t, log.Log.Entries, 1)
+	assert.True(t, started.Add(time.Second).Equal(log.Log.Entries[0].StartedDateTime))
+	assert.Equal(t, float64(2000), log.Log.Entries[0].Time)
+}
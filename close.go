@@ -0,0 +1,72 @@
+package port
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClosed is returned by the requests sent through a transport after its
+// Close method was called
+var ErrClosed = errors.New("transport is closed")
+
+// shutdown tracks the closing of a transport: it wakes the requests waiting
+// on it and the background goroutines it started. The zero value is open
+type shutdown struct {
+	mu       sync.Mutex // guards the fields below
+	done     chan struct{}
+	isClosed bool
+	wg       sync.WaitGroup // background goroutines
+}
+
+// closed returns a channel closed once the transport is closed
+func (s *shutdown) closed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	return s.done
+}
+
+// isDone reports whether the transport is closed
+func (s *shutdown) isDone() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isClosed
+}
+
+// goroutine runs fn in a goroutine waited for by close. It returns false,
+// without running fn, once the transport is closed
+func (s *shutdown) goroutine(fn func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed {
+		return false
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+// close marks the transport as closed, then waits for its goroutines. It
+// returns false when the transport was already closed
+func (s *shutdown) close() bool {
+	s.mu.Lock()
+	if s.isClosed {
+		s.mu.Unlock()
+		s.wg.Wait()
+		return false
+	}
+	s.isClosed = true
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	close(s.done)
+	s.mu.Unlock()
+	s.wg.Wait()
+	return true
+}
@@ -0,0 +1,134 @@
+package port
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// slowTransport answers after delay, unless the request is canceled first
+func slowTransport(delay time.Duration) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		select {
+		case <-time.After(delay):
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Request: req}, nil
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	})
+}
+
+// assertNoGoroutineLeak fails unless the number of goroutines gets back to
+// before
+func assertNoGoroutineLeak(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func roundTripErr(t *testing.T, rt http.RoundTripper) error {
+	t.Helper()
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := rt.RoundTrip(req)
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+	return err
+}
+
+func TestRateLimiter_Close(t *testing.T) {
+	l := NewRateLimiter(&Recorder{}, 0.001, 1)
+	require.NoError(t, roundTripErr(t, l))
+
+	// the next request waits for a token
+	errs := make(chan error)
+	go func() { errs <- roundTripErr(t, l) }()
+	time.Sleep(20 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, l.Close())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, ErrClosed, <-errs)
+	assert.Equal(t, ErrClosed, roundTripErr(t, l))
+}
+
+func TestConcurrencyLimiter_Close(t *testing.T) {
+	l := NewConcurrencyLimiter(slowTransport(time.Hour), 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	// the first request holds the slot, the second waits for it
+	first := make(chan error)
+	go func() {
+		_, err := l.RoundTrip(req)
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan error)
+	go func() { second <- roundTripErr(t, l) }()
+	time.Sleep(20 * time.Millisecond)
+
+	require.NoError(t, l.Close())
+	require.NoError(t, l.Close())
+	assert.Equal(t, ErrClosed, <-second)
+	assert.Equal(t, ErrClosed, roundTripErr(t, l))
+
+	// the request in flight keeps going
+	select {
+	case <-first:
+		t.Fatal("the request in flight was interrupted")
+	default:
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-first)
+}
+
+func TestHedging_Close(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	var mu sync.Mutex
+	attempts := 0
+	h := NewHedging(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		attempts++
+		n := attempts
+		mu.Unlock()
+		// the first attempt is slow, the hedged one wins
+		if n == 1 {
+			return slowTransport(time.Hour).RoundTrip(req)
+		}
+		return slowTransport(0).RoundTrip(req)
+	}), 10*time.Millisecond)
+
+	require.NoError(t, roundTripErr(t, h))
+	// the canceled attempt is released in the background, Close waits for it
+	require.NoError(t, h.Close())
+	assert.Equal(t, ErrClosed, roundTripErr(t, h))
+	assert.NoError(t, h.Close())
+	assertNoGoroutineLeak(t, before)
+}
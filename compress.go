@@ -0,0 +1,171 @@
+package port
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GzipRequestBody returns a RequestModifier compressing request bodies larger
+// than minSize bytes with gzip. Bodies already carrying a Content-Encoding are
+// left untouched. The compressed body is buffered so it stays replayable
+func GzipRequestBody(minSize int) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+			return nil
+		}
+		if req.ContentLength > 0 && req.ContentLength <= int64(minSize) {
+			return nil
+		}
+		body, err := ReadAndRestoreBody(req, DefaultMaxBodySize)
+		if err != nil {
+			return errors.Wrap(err, "unable to compress request body")
+		}
+		if len(body) <= minSize {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(body); err != nil {
+			return errors.Wrap(err, "unable to compress request body")
+		}
+		if err = zw.Close(); err != nil {
+			return errors.Wrap(err, "unable to compress request body")
+		}
+
+		setBody(req, buf.Bytes())
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Del("Content-Length")
+		return nil
+	})
+}
+
+// DecompressResponse returns a ResponseModifier transparently decoding gzip
+// and deflate response bodies. Go only does so when it added Accept-Encoding
+// itself, this handles servers compressing unasked. The Content-Encoding and
+// Content-Length headers are removed from decoded responses
+func DecompressResponse() ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		if newReader := builtinDecoder(contentEncoding(resp)); newReader != nil {
+			decodeResponse(resp, newReader)
+		}
+		return nil
+	})
+}
+
+// ContentDecoder decodes the bodies of a Content-Encoding not supported by the
+// standard library, e.g. brotli with a third party package
+type ContentDecoder interface {
+	// Encoding is the name of the encoding, e.g. "br"
+	Encoding() string
+	// NewReader returns a reader decoding r
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// NegotiateEncoding returns a RequestModifier advertising gzip and the
+// encodings of decoders in Accept-Encoding, and a ResponseModifier decoding the
+// Content-Encoding chosen by the server among gzip, deflate and the encodings
+// of decoders. An Accept-Encoding set by the caller is left untouched. As with
+// DecompressResponse, the Content-Encoding and Content-Length headers are
+// removed from decoded responses
+func NegotiateEncoding(decoders ...ContentDecoder) (RequestModifier, ResponseModifier) {
+	byName := make(map[string]ContentDecoder, len(decoders))
+	accept := []string{"gzip"}
+	for _, d := range decoders {
+		name := strings.ToLower(d.Encoding())
+		if _, ok := byName[name]; ok || builtinDecoder(name) != nil {
+			continue
+		}
+		byName[name] = d
+		accept = append(accept, name)
+	}
+	acceptEncoding := strings.Join(accept, ", ")
+
+	reqMod := RequestModifierFunc(func(req *http.Request) error {
+		if _, ok := req.Header["Accept-Encoding"]; !ok {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		return nil
+	})
+	resMod := ResponseModifierFunc(func(resp *http.Response) error {
+		encoding := contentEncoding(resp)
+		newReader := builtinDecoder(encoding)
+		if d, ok := byName[encoding]; ok {
+			newReader = d.NewReader
+		}
+		if newReader != nil {
+			decodeResponse(resp, newReader)
+		}
+		return nil
+	})
+	return reqMod, resMod
+}
+
+func contentEncoding(resp *http.Response) string {
+	return strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+}
+
+// builtinDecoder returns the reader decoding encoding with the standard
+// library, nil if it is not supported
+func builtinDecoder(encoding string) func(r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+	case "deflate":
+		return zlib.NewReader
+	}
+	return nil
+}
+
+// decodeResponse replaces the body of resp with its decoded content
+func decodeResponse(resp *http.Response, newReader func(r io.Reader) (io.ReadCloser, error)) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+	resp.Body = &decodingReader{rc: resp.Body, newReader: newReader}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReader lazily wraps its body in a decompressing reader, so the
+// stream header is only read when the caller starts reading
+type decodingReader struct {
+	rc        io.ReadCloser
+	newReader func(r io.Reader) (io.ReadCloser, error)
+	zr        io.ReadCloser
+	err       error
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	if d.zr == nil && d.err == nil {
+		zr, err := d.newReader(d.rc)
+		switch {
+		case err == io.EOF:
+			// an empty body, io.EOF is kept unwrapped to end the reads
+			d.err = err
+		case err != nil:
+			d.err = errors.Wrap(err, "unable to decompress response body")
+		default:
+			d.zr = zr
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.zr.Read(p)
+}
+
+func (d *decodingReader) Close() error {
+	if d.zr != nil {
+		_ = d.zr.Close()
+	}
+	return d.rc.Close()
+}
@@ -0,0 +1,259 @@
+package port
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipRequestBody(t *testing.T) {
+	payload := strings.Repeat(`{"key":"value"}`, 100)
+	var received, encoding string
+	var length int64
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		length = r.ContentLength
+		var body io.Reader = r.Body
+		if encoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body = zr
+		}
+		b, _ := io.ReadAll(body)
+		received = string(b)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, GzipRequestBody(1024))
+
+	resp, err := c.Post(s.URL, "application/json", strings.NewReader(payload))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "gzip", encoding)
+	assert.Less(t, length, int64(len(payload)))
+	assert.Equal(t, payload, received)
+
+	// small bodies are sent as is
+	resp2, err := c.Post(s.URL, "application/json", strings.NewReader(`{"small":true}`))
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+
+	assert.Empty(t, encoding)
+	assert.Equal(t, `{"small":true}`, received)
+}
+
+func TestGzipRequestBody_Replayable(t *testing.T) {
+	payload := strings.Repeat("a", 200)
+	req, err := http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader(payload)))
+	require.NoError(t, err)
+
+	require.NoError(t, GzipRequestBody(100).Intercept(req))
+	require.NotNil(t, req.GetBody)
+
+	for i := 0; i < 2; i++ {
+		rc, err := req.GetBody()
+		require.NoError(t, err)
+		zr, err := gzip.NewReader(rc)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(b))
+	}
+}
+
+func TestGzipRequestBody_AlreadyEncoded(t *testing.T) {
+	payload := strings.Repeat("a", 200)
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("Content-Encoding", "br")
+
+	require.NoError(t, GzipRequestBody(100).Intercept(req))
+	assert.Equal(t, "br", req.Header.Get("Content-Encoding"))
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, string(b))
+}
+
+func TestDecompressResponse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var zw io.WriteCloser
+		switch r.URL.Path {
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			zw = zlib.NewWriter(w)
+		case "/plain":
+			_, _ = w.Write([]byte("plain text"))
+			return
+		default:
+			w.Header().Set("Content-Encoding", "gzip")
+			zw = gzip.NewWriter(w)
+		}
+		_, _ = zw.Write([]byte("compressed text"))
+		_ = zw.Close()
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	// do not let the transport negotiate and decode gzip itself
+	c.Transport.(*http.Transport).DisableCompression = true
+	it := NewRequestInterceptor(c.Transport, nil)
+	it.ResponseModifier = DecompressResponse()
+	c.Transport = it
+
+	for path, want := range map[string]string{"/gzip": "compressed text", "/deflate": "compressed text", "/plain": "plain text"} {
+		req, err := http.NewRequest("GET", s.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+
+		assert.Empty(t, resp.Header.Get("Content-Encoding"), path)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(b), path)
+		_ = resp.Body.Close()
+
+		// the body has been read to EOF, the interceptor released the request
+		_, ok := it.ModifiedRequest(req)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestDecompressResponse_Empty(t *testing.T) {
+	it := NewInterceptor(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": {"gzip"}},
+			Body:       io.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}), WithResponseModifier(DecompressResponse()))
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	require.NoError(t, err)
+	resp, err := it.RoundTrip(req)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, b)
+
+	// EOF released the request before Close
+	_, ok := it.ModifiedRequest(req)
+	assert.False(t, ok)
+	_ = resp.Body.Close()
+}
+
+func TestDecompressResponse_Invalid(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"gzip"}},
+		Body:   io.NopCloser(strings.NewReader("not gzip")),
+	}
+	require.NoError(t, DecompressResponse().ModifyResponse(resp))
+	_, err := io.ReadAll(resp.Body)
+	require.Error(t, err)
+}
+
+// stubBrotli stands for a brotli decoder, the "br" bodies of the tests being
+// base64 encoded
+type stubBrotli struct{}
+
+func (stubBrotli) Encoding() string { return "br" }
+
+func (stubBrotli) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("accepted", r.Header.Get("Accept-Encoding"))
+		accepted := strings.Split(r.Header.Get("Accept-Encoding"), ", ")
+		switch {
+		case r.URL.Path == "/br" && containsFold(accepted, "br"):
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString([]byte("brotli text"))))
+		case containsFold(accepted, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = zw.Write([]byte("gzip text"))
+			_ = zw.Close()
+		default:
+			_, _ = w.Write([]byte("plain text"))
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	get := func(c *http.Client, path, acceptEncoding string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", s.URL+path, nil)
+		require.NoError(t, err)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp, string(b)
+	}
+
+	reqMod, resMod := NegotiateEncoding(stubBrotli{})
+	c := s.Client()
+	base := c.Transport
+	c.Transport = NewInterceptor(base, WithRequestModifier(reqMod), WithResponseModifier(resMod))
+
+	resp, body := get(c, "/br", "")
+	assert.Equal(t, "gzip, br", resp.Header.Get("accepted"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "brotli text", body)
+	assert.True(t, resp.Uncompressed)
+
+	resp, body = get(c, "/gzip", "")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "gzip text", body)
+
+	// the encodings of the caller are kept
+	resp, body = get(c, "/br", "identity")
+	assert.Equal(t, "identity", resp.Header.Get("accepted"))
+	assert.Equal(t, "plain text", body)
+
+	// without decoder, brotli is not advertised
+	reqMod, resMod = NegotiateEncoding()
+	c.Transport = NewInterceptor(base, WithRequestModifier(reqMod), WithResponseModifier(resMod))
+	resp, body = get(c, "/br", "")
+	assert.Equal(t, "gzip", resp.Header.Get("accepted"))
+	assert.Equal(t, "gzip text", body)
+}
+
+func TestNegotiateEncoding_Unknown(t *testing.T) {
+	_, resMod := NegotiateEncoding(stubBrotli{})
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"zstd"}},
+		Body:   io.NopCloser(strings.NewReader("zstd frame")),
+	}
+	require.NoError(t, resMod.ModifyResponse(resp))
+	assert.Equal(t, "zstd", resp.Header.Get("Content-Encoding"))
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "zstd frame", string(b))
+}
@@ -0,0 +1,93 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// NewConcurrencyLimiter returns a roundtripper allowing at most n requests in
+// flight at the same time
+func NewConcurrencyLimiter(baseTransport http.RoundTripper, n int) *ConcurrencyLimiter {
+	if n < 1 {
+		n = 1
+	}
+	return &ConcurrencyLimiter{
+		Base: baseTransport,
+		sem:  make(chan struct{}, n),
+	}
+}
+
+// ConcurrencyLimiter caps the number of requests in flight. A slot is taken
+// before dispatch and given back once the response body is read or closed, or
+// when the request fails. Requests waiting for a slot give up when their
+// context is done. Once closed, waiting and new requests fail with ErrClosed
+type ConcurrencyLimiter struct {
+	Base     http.RoundTripper
+	sem      chan struct{}
+	shutdown shutdown
+}
+
+// RoundTrip waits for a slot then sends the request
+func (l *ConcurrencyLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if l.shutdown.isDone() {
+		closeBody(req)
+		return nil, ErrClosed
+	}
+	if l.sem == nil {
+		// not built with NewConcurrencyLimiter, there is no limit
+		return l.base().RoundTrip(req)
+	}
+	select {
+	case l.sem <- struct{}{}:
+	case <-req.Context().Done():
+		closeBody(req)
+		return nil, req.Context().Err()
+	case <-l.shutdown.closed():
+		closeBody(req)
+		return nil, ErrClosed
+	}
+
+	var once sync.Once
+	release := func() { once.Do(func() { <-l.sem }) }
+
+	res, err := l.base().RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if res.Body == nil || res.Body == http.NoBody {
+		release()
+		return res, nil
+	}
+	// an abandoned body must not hold its slot forever
+	stop := context.AfterFunc(req.Context(), release)
+	res.Body = &onEOFReader{
+		rc: res.Body,
+		fn: func() {
+			stop()
+			release()
+		},
+	}
+	return res, nil
+}
+
+// Close makes the waiting and future requests fail with ErrClosed. It can be
+// called several times
+func (l *ConcurrencyLimiter) Close() error {
+	l.shutdown.close()
+	return nil
+}
+
+// Wrap sets base as the Base of ConcurrencyLimiter, so it can be used with Stack
+func (l *ConcurrencyLimiter) Wrap(base http.RoundTripper) http.RoundTripper {
+	l.Base = base
+	return l
+}
+
+func (l *ConcurrencyLimiter) base() http.RoundTripper {
+	if l.Base != nil {
+		return l.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,87 @@
+package port
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	var current, max int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewConcurrencyLimiter(c.Transport, 3)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(s.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, atomic.LoadInt32(&max) <= 3, "max concurrency %d", max)
+	assert.True(t, atomic.LoadInt32(&max) > 1)
+}
+
+func TestConcurrencyLimiter_Cancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("body"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewConcurrencyLimiter(c.Transport, 1)
+
+	// the unread body holds the only slot
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	_, err = c.Do(req.WithContext(cctx))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// closing the body gives the slot back
+	_ = resp.Body.Close()
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,126 @@
+package port
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// sniffLen is the number of bytes considered by http.DetectContentType
+const sniffLen = 512
+
+// SniffContentType returns a RequestModifier setting the Content-Type of
+// requests missing one from the first 512 bytes of their body. JSON bodies are
+// detected as application/json, other ones use http.DetectContentType. The
+// body has to be replayable (req.GetBody set), it is read from a fresh copy.
+// Requests without a body or with a Content-Type are left untouched
+func SniffContentType() RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+			return nil
+		}
+		if _, ok := req.Header["Content-Type"]; ok {
+			return nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return errors.Wrap(err, "unable to sniff content type")
+		}
+		defer body.Close()
+		head, err := io.ReadAll(io.LimitReader(body, sniffLen))
+		if err != nil {
+			return errors.Wrap(err, "unable to sniff content type")
+		}
+		if len(head) == 0 {
+			return nil
+		}
+		req.Header.Set("Content-Type", detectContentType(head))
+		return nil
+	})
+}
+
+func detectContentType(head []byte) string {
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return "application/json"
+	}
+	return http.DetectContentType(head)
+}
+
+// JSONDefaults returns a RequestModifier setting Accept: application/json on
+// requests without an Accept header, and Content-Type: application/json;
+// charset=utf-8 on requests with a body and without a Content-Type. The
+// headers set by the caller are left untouched
+func JSONDefaults() RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if _, ok := req.Header["Accept"]; !ok {
+			req.Header.Set("Accept", "application/json")
+		}
+		if req.Body == nil || req.Body == http.NoBody {
+			return nil
+		}
+		if _, ok := req.Header["Content-Type"]; !ok {
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		}
+		return nil
+	})
+}
+
+// DigestAlgo is a hash algorithm used by ContentDigest
+type DigestAlgo int
+
+const (
+	// DigestSHA256 sets Content-Digest: sha-256=:<base64>: (RFC 9530)
+	DigestSHA256 DigestAlgo = iota
+	// DigestMD5 sets the legacy Content-MD5: <base64> header (RFC 1864)
+	DigestMD5
+)
+
+// ContentDigest returns a RequestModifier setting the digest of the request
+// body with algo. A replayable body is hashed from a fresh copy, other ones
+// are buffered in memory, up to DefaultMaxBodySize, and restored. Requests
+// without body get the digest of an empty body
+func ContentDigest(algo DigestAlgo) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		var h hash.Hash
+		if algo == DigestMD5 {
+			h = md5.New()
+		} else {
+			h = sha256.New()
+		}
+
+		switch {
+		case req.Body == nil || req.Body == http.NoBody:
+		case req.GetBody != nil:
+			body, err := req.GetBody()
+			if err != nil {
+				return errors.Wrap(err, "unable to compute content digest")
+			}
+			_, err = io.Copy(h, body)
+			_ = body.Close()
+			if err != nil {
+				return errors.Wrap(err, "unable to compute content digest")
+			}
+		default:
+			b, err := ReadAndRestoreBody(req, DefaultMaxBodySize)
+			if err != nil {
+				return errors.Wrap(err, "unable to compute content digest")
+			}
+			h.Write(b)
+		}
+
+		sum := base64.StdEncoding.EncodeToString(h.Sum(nil))
+		if algo == DigestMD5 {
+			req.Header.Set("Content-MD5", sum)
+		} else {
+			req.Header.Set("Content-Digest", "sha-256=:"+sum+":")
+		}
+		return nil
+	})
+}
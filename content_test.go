@@ -0,0 +1,126 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSniffContentType(t *testing.T) {
+	var contentType, body string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, SniffContentType())
+
+	cases := map[string]string{
+		` {"hello":"world"}`:  "application/json",
+		`[1,2,3]`:             "application/json",
+		"<html><body></html>": "text/html; charset=utf-8",
+		"plain text":          "text/plain; charset=utf-8",
+	}
+	for payload, want := range cases {
+		req, err := http.NewRequest("POST", s.URL, strings.NewReader(payload))
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		assert.Equal(t, want, contentType, payload)
+		assert.Equal(t, payload, body)
+	}
+}
+
+func TestSniffContentType_Untouched(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/vnd.custom+json")
+	require.NoError(t, SniffContentType().Intercept(req))
+	assert.Equal(t, "application/vnd.custom+json", req.Header.Get("Content-Type"))
+
+	req, err = http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, SniffContentType().Intercept(req))
+	assert.Empty(t, req.Header.Get("Content-Type"))
+}
+
+func TestJSONDefaults(t *testing.T) {
+	var accept, contentType []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept, contentType = r.Header.Values("Accept"), r.Header.Values("Content-Type")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, JSONDefaults())
+
+	// a request without a body only gets Accept
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	discardResponse(resp)
+	assert.Equal(t, []string{"application/json"}, accept)
+	assert.Empty(t, contentType)
+
+	// a request with a body gets both
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	discardResponse(resp)
+	assert.Equal(t, []string{"application/json"}, accept)
+	assert.Equal(t, []string{"application/json; charset=utf-8"}, contentType)
+	assert.Empty(t, req.Header.Get("Content-Type"))
+
+	// the headers of the caller are kept
+	req, err = http.NewRequest("PUT", s.URL, strings.NewReader("a,b"))
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/csv")
+	req.Header.Set("Content-Type", "text/csv")
+	resp, err = c.Do(req)
+	require.NoError(t, err)
+	discardResponse(resp)
+	assert.Equal(t, []string{"text/csv"}, accept)
+	assert.Equal(t, []string{"text/csv"}, contentType)
+}
+
+func TestContentDigest(t *testing.T) {
+	// example of RFC 9530
+	req, err := http.NewRequest("POST", "http://example.com", strings.NewReader(`{"hello": "world"}`))
+	require.NoError(t, err)
+	require.NoError(t, ContentDigest(DigestSHA256).Intercept(req))
+	assert.Equal(t, "sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:", req.Header.Get("Content-Digest"))
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello": "world"}`, string(b))
+
+	// a body without GetBody is buffered and restored
+	req, err = http.NewRequest("PUT", "http://example.com", io.NopCloser(strings.NewReader("hello world")))
+	require.NoError(t, err)
+	require.NoError(t, ContentDigest(DigestMD5).Intercept(req))
+	assert.Equal(t, "XrY7u+Ae7tCTyyK7j1rNww==", req.Header.Get("Content-MD5"))
+	assert.Empty(t, req.Header.Get("Content-Digest"))
+	b, err = io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+
+	req, err = http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, ContentDigest(DigestSHA256).Intercept(req))
+	assert.Equal(t, "sha-256=:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=:", req.Header.Get("Content-Digest"))
+}
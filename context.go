@@ -0,0 +1,53 @@
+package port
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type modifiersKey struct{}
+
+// WithModifier returns a copy of ctx carrying mod. RequestIntercepter applies
+// the modifiers found in the request context after its own modifier, in the
+// order they were added. A nil modifier is ignored
+func WithModifier(ctx context.Context, mod RequestModifier) context.Context {
+	if mod == nil {
+		return ctx
+	}
+	existing := contextModifiers(ctx)
+	mods := make([]RequestModifier, len(existing), len(existing)+1)
+	copy(mods, existing)
+	return context.WithValue(ctx, modifiersKey{}, append(mods, mod))
+}
+
+func contextModifiers(ctx context.Context) []RequestModifier {
+	mods, _ := ctx.Value(modifiersKey{}).([]RequestModifier)
+	return mods
+}
+
+// Baggage returns a RequestModifier propagating values of the request context
+// as headers: for every context key of keys present in the context, the
+// header it maps to is set to the value. Strings are used as is, other values
+// through fmt.Stringer or %v. Keys missing from the context, or holding a nil
+// value, are skipped
+func Baggage(keys map[any]string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		ctx := req.Context()
+		for key, header := range keys {
+			var value string
+			switch v := ctx.Value(key).(type) {
+			case nil:
+				continue
+			case string:
+				value = v
+			case fmt.Stringer:
+				value = v.String()
+			default:
+				value = fmt.Sprintf("%v", v)
+			}
+			req.Header.Set(header, value)
+		}
+		return nil
+	})
+}
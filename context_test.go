@@ -0,0 +1,128 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithModifier(t *testing.T) {
+	var received []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header["X-Step"]
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, appendHeader("global"))
+
+	ctx := WithModifier(context.Background(), appendHeader("first"))
+	ctx = WithModifier(ctx, nil)
+	ctx = WithModifier(ctx, appendHeader("second"))
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"global", "first", "second"}, received)
+
+	// requests without the context are left untouched
+	received = nil
+	resp2, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, []string{"global"}, received)
+}
+
+func TestWithModifier_ContextOnly(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Idempotency-Key")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, nil)
+
+	ctx := WithModifier(context.Background(), RequestModifierFunc(func(r *http.Request) error {
+		r.Header.Set("Idempotency-Key", "once")
+		return nil
+	}))
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req.WithContext(ctx))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "once", received)
+}
+
+func TestWithModifier_Branches(t *testing.T) {
+	root := WithModifier(context.Background(), appendHeader("root"))
+	a := WithModifier(root, appendHeader("a"))
+	b := WithModifier(root, appendHeader("b"))
+
+	assert.Len(t, contextModifiers(root), 1)
+	assert.Len(t, contextModifiers(a), 2)
+	assert.Len(t, contextModifiers(b), 2)
+}
+
+func TestWithModifier_Error(t *testing.T) {
+	it := NewRequestInterceptor(nil, nil)
+	failure := errors.New("failure")
+	ctx := WithModifier(context.Background(), RequestModifierFunc(func(r *http.Request) error {
+		return failure
+	}))
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	_, err = it.RoundTrip(req.WithContext(ctx))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, failure))
+}
+
+type tenantKey struct{}
+type correlationKey struct{}
+type attemptKey struct{}
+type missingKey struct{}
+
+type tenant struct{ id int }
+
+func (t tenant) String() string { return fmt.Sprintf("tenant-%d", t.id) }
+
+func TestBaggage(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantKey{}, tenant{42})
+	ctx = context.WithValue(ctx, correlationKey{}, "corr-1")
+	ctx = context.WithValue(ctx, attemptKey{}, 3)
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	err = Baggage(map[any]string{
+		tenantKey{}:      "Tenant-Id",
+		correlationKey{}: "Correlation-Id",
+		attemptKey{}:     "X-Attempt",
+		missingKey{}:     "X-Missing",
+	}).Intercept(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "tenant-42", req.Header.Get("Tenant-Id"))
+	assert.Equal(t, "corr-1", req.Header.Get("Correlation-Id"))
+	assert.Equal(t, "3", req.Header.Get("X-Attempt"))
+	_, ok := req.Header["X-Missing"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,67 @@
+package port
+
+import (
+	"net/http"
+)
+
+// NewCookieTransport returns a roundtripper persisting cookies in jar
+func NewCookieTransport(baseTransport http.RoundTripper, jar http.CookieJar) *CookieTransport {
+	return &CookieTransport{
+		Base: baseTransport,
+		Jar:  jar,
+	}
+}
+
+// CookieTransport attaches the cookies of Jar to outgoing requests and stores
+// the cookies set by responses, keyed by request URL as the jar contract
+// expects. Unlike http.Client.Jar it also works for requests sent directly
+// through the transport. Every redirect hop being its own round trip, cookies
+// set by a redirect response are stored for the URL that set them.
+// Cookies already present on the request take precedence over the jar ones
+type CookieTransport struct {
+	Base http.RoundTripper
+	Jar  http.CookieJar
+}
+
+// RoundTrip adds the jar cookies to the request and saves the response ones
+func (t *CookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Jar == nil {
+		return t.base().RoundTrip(req)
+	}
+
+	if cookies := t.Jar.Cookies(req.URL); len(cookies) > 0 {
+		present := make(map[string]bool)
+		for _, c := range req.Cookies() {
+			present[c.Name] = true
+		}
+		req2 := cloneRequest(req) // per RoundTripper contract
+		for _, c := range cookies {
+			if !present[c.Name] {
+				req2.AddCookie(c)
+			}
+		}
+		req = req2
+	}
+
+	res, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cookies := res.Cookies(); len(cookies) > 0 {
+		t.Jar.SetCookies(req.URL, cookies)
+	}
+	return res, nil
+}
+
+// Wrap sets base as the Base of CookieTransport, so it can be used with Stack
+func (t *CookieTransport) Wrap(base http.RoundTripper) http.RoundTripper {
+	t.Base = base
+	return t
+}
+
+func (t *CookieTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
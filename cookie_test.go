@@ -0,0 +1,66 @@
+package port
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieTransport(t *testing.T) {
+	var received []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+		case "/redirect":
+			http.SetCookie(w, &http.Cookie{Name: "hop", Value: "1", Path: "/"})
+			http.Redirect(w, r, "/profile", http.StatusFound)
+		default:
+			received = nil
+			for _, c := range r.Cookies() {
+				received = append(received, c.Name+"="+c.Value)
+			}
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	tr := NewCookieTransport(s.Client().Transport, jar)
+
+	// requests sent to the transport directly, without any client jar
+	do := func(path string, cookies ...*http.Cookie) *http.Request {
+		req, err := http.NewRequest("GET", s.URL+path, nil)
+		require.NoError(t, err)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		resp, err := tr.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return req
+	}
+
+	do("/login")
+	req := do("/profile")
+	assert.Equal(t, []string{"session=s3cr3t"}, received)
+	assert.Empty(t, req.Header.Get("Cookie"))
+
+	// the caller cookie wins
+	do("/profile", &http.Cookie{Name: "session", Value: "mine"})
+	assert.Equal(t, []string{"session=mine"}, received)
+
+	// cookies set along a redirect chain followed by a client
+	c := &http.Client{Transport: tr}
+	resp, err := c.Get(s.URL + "/redirect")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.ElementsMatch(t, []string{"session=s3cr3t", "hop=1"}, received)
+}
@@ -0,0 +1,73 @@
+package port
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineFormat is the way DeadlineHeader writes the request deadline
+type DeadlineFormat int
+
+const (
+	// DeadlineMilliseconds writes the remaining time in milliseconds
+	DeadlineMilliseconds DeadlineFormat = iota
+	// DeadlineRFC3339 writes the deadline itself, in UTC with millisecond
+	// precision
+	DeadlineRFC3339
+	// DeadlineGRPC writes the remaining time like the grpc-timeout header: at
+	// most 8 digits followed by a unit, e.g. "100000u" or "300000m"
+	DeadlineGRPC
+)
+
+// DeadlineHeader returns a RequestModifier writing the deadline of the request
+// context in the name header, in the given format, so the backend can give up
+// when the caller no longer waits. Requests without deadline are left
+// untouched, an expired deadline is written as no time left
+func DeadlineHeader(name string, format DeadlineFormat) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		deadline, ok := req.Context().Deadline()
+		if !ok {
+			return nil
+		}
+		remaining := deadline.Sub(clockFrom(req.Context()).Now())
+		if remaining < 0 {
+			remaining = 0
+		}
+		var value string
+		switch format {
+		case DeadlineRFC3339:
+			value = deadline.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+		case DeadlineGRPC:
+			value = grpcTimeout(remaining)
+		default:
+			value = strconv.FormatInt(remaining.Milliseconds(), 10)
+		}
+		req.Header.Set(name, value)
+		return nil
+	})
+}
+
+// grpcTimeout encodes d in the most precise unit fitting in 8 digits,
+// truncating the remainder so the backend is never told it has more time than
+// the caller has left
+func grpcTimeout(d time.Duration) string {
+	const maxValue = 99999999
+	units := []struct {
+		d    time.Duration
+		unit string
+	}{
+		{time.Nanosecond, "n"},
+		{time.Microsecond, "u"},
+		{time.Millisecond, "m"},
+		{time.Second, "S"},
+		{time.Minute, "M"},
+		{time.Hour, "H"},
+	}
+	for _, u := range units {
+		if v := d / u.d; v <= maxValue {
+			return strconv.FormatInt(int64(v), 10) + u.unit
+		}
+	}
+	return strconv.Itoa(maxValue) + "H"
+}
@@ -0,0 +1,64 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deadlineHeader(t *testing.T, ctx context.Context, format DeadlineFormat) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, DeadlineHeader("X-Request-Deadline", format).Intercept(req))
+	return req.Header.Get("X-Request-Deadline")
+}
+
+func TestDeadlineHeader(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ms, err := strconv.Atoi(deadlineHeader(t, ctx, DeadlineMilliseconds))
+	require.NoError(t, err)
+	assert.InDelta(t, 2000, ms, 100)
+
+	abs, err := time.Parse(time.RFC3339Nano, deadlineHeader(t, ctx, DeadlineRFC3339))
+	require.NoError(t, err)
+	assert.WithinDuration(t, deadline, abs, time.Millisecond)
+
+	grpc := deadlineHeader(t, ctx, DeadlineGRPC)
+	assert.Regexp(t, `^\d{1,8}u$`, grpc)
+}
+
+func TestDeadlineHeader_None(t *testing.T) {
+	assert.Empty(t, deadlineHeader(t, context.Background(), DeadlineMilliseconds))
+
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	assert.Equal(t, "0", deadlineHeader(t, ctx, DeadlineMilliseconds))
+	assert.Equal(t, "0n", deadlineHeader(t, ctx, DeadlineGRPC))
+}
+
+func TestGRPCTimeout(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		50 * time.Nanosecond:    "50n",
+		100 * time.Millisecond:  "100000u",
+		30 * time.Second:        "30000000u",
+		5 * time.Minute:         "300000m",
+		48 * time.Hour:          "172800S",
+		1500 * time.Microsecond: "1500000n",
+		// the sub-unit remainder is truncated, never rounded up
+		100*time.Millisecond + time.Nanosecond:     "100000u",
+		100*time.Millisecond + 999*time.Nanosecond: "100000u",
+		30*time.Second + time.Nanosecond:           "30000000u",
+		100*time.Second + 999*time.Microsecond:     "100000m",
+	} {
+		assert.Equal(t, want, grpcTimeout(d), d.String())
+	}
+}
@@ -0,0 +1,251 @@
+package port
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// NewDigestAuth returns a roundtripper authenticating requests with HTTP
+// Digest authentication
+func NewDigestAuth(baseTransport http.RoundTripper, username, password string) *DigestAuth {
+	return &DigestAuth{
+		Base:     baseTransport,
+		Username: username,
+		Password: password,
+	}
+}
+
+// DigestAuth answers the Digest challenges of servers (RFC 7616): on a 401
+// carrying a WWW-Authenticate Digest challenge, the request is sent again with
+// the Authorization computed from it. The challenge is then kept per host, so
+// the next requests are authenticated up front with an increasing nonce count,
+// until the server rejects the nonce as stale. MD5, SHA-256 and their -sess
+// variants are supported with the auth quality of protection. The body is
+// replayed with req.GetBody, requests without it get the 401 back, as do
+// requests already carrying an Authorization header
+type DigestAuth struct {
+	Base     http.RoundTripper
+	Username string
+	Password string
+
+	mu         sync.Mutex // guards challenges
+	challenges map[string]*digestChallenge
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string // auth, or empty for RFC 2069 servers
+	stale     bool
+	nc        int // requests sent with nonce
+}
+
+// RoundTrip sends the request, answering the Digest challenge of the server
+func (t *DigestAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "" {
+		return t.base().RoundTrip(req)
+	}
+	host := strings.ToLower(req.URL.Host)
+
+	first := req
+	authorized := false
+	if auth, ok := t.authorization(host, req); ok {
+		first = cloneRequest(req) // per RoundTripper contract
+		first.Header.Set("Authorization", auth)
+		authorized = true
+	}
+	res, err := t.base().RoundTrip(first)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	c, ok := parseDigestChallenge(res.Header)
+	if !ok || (authorized && !c.stale) {
+		// wrong credentials, or a challenge this transport cannot answer
+		return res, nil
+	}
+	next, err := rewindRequest(req)
+	if err != nil {
+		return res, nil
+	}
+	t.mu.Lock()
+	if t.challenges == nil {
+		t.challenges = make(map[string]*digestChallenge)
+	}
+	t.challenges[host] = c
+	t.mu.Unlock()
+
+	auth, _ := t.authorization(host, next)
+	discardResponse(res)
+	next.Header = req.Header.Clone()
+	next.Header.Set("Authorization", auth)
+	return t.base().RoundTrip(next)
+}
+
+// authorization returns the Authorization header answering the challenge
+// known for host, if any
+func (t *DigestAuth) authorization(host string, req *http.Request) (string, bool) {
+	t.mu.Lock()
+	c, ok := t.challenges[host]
+	if !ok {
+		t.mu.Unlock()
+		return "", false
+	}
+	c.nc++
+	challenge := *c
+	t.mu.Unlock()
+
+	cnonce := make([]byte, 16)
+	if _, err := rand.Read(cnonce); err != nil {
+		return "", false
+	}
+	return challenge.authorization(t.Username, t.Password, req.Method, req.URL.RequestURI(), hex.EncodeToString(cnonce)), true
+}
+
+// authorization computes the Authorization header of a request
+func (c *digestChallenge) authorization(username, password, method, uri, cnonce string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	h := c.hash
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	nc := fmt.Sprintf("%08x", c.nc)
+
+	var b strings.Builder
+	b.WriteString("Digest username=" + quoteAuthParam(username) +
+		", realm=" + quoteAuthParam(c.realm) +
+		", nonce=" + quoteAuthParam(c.nonce) +
+		", uri=" + quoteAuthParam(uri))
+	if c.algorithm != "" {
+		b.WriteString(", algorithm=" + c.algorithm)
+	}
+	if c.qop == "" {
+		b.WriteString(", response=" + quoteAuthParam(h(ha1+":"+c.nonce+":"+ha2)))
+	} else {
+		b.WriteString(", response=" + quoteAuthParam(h(ha1+":"+c.nonce+":"+nc+":"+cnonce+":"+c.qop+":"+ha2)) +
+			", qop=" + c.qop + ", nc=" + nc + ", cnonce=" + quoteAuthParam(cnonce))
+	}
+	if c.opaque != "" {
+		b.WriteString(", opaque=" + quoteAuthParam(c.opaque))
+	}
+	return b.String()
+}
+
+// hash returns the hex digest of s with the algorithm of the challenge
+func (c *digestChallenge) hash(s string) string {
+	var h hash.Hash
+	if strings.HasPrefix(strings.ToUpper(c.algorithm), "SHA-256") {
+		h = sha256.New()
+	} else {
+		h = md5.New()
+	}
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseDigestChallenge returns the Digest challenge of a 401 response, if it
+// is one DigestAuth can answer
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	for _, v := range h.Values("Www-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		switch strings.ToUpper(c.algorithm) {
+		case "", "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+		default:
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if strings.EqualFold(strings.TrimSpace(q), "auth") {
+					c.qop = "auth"
+				}
+			}
+			if c.qop == "" {
+				// only auth-int is offered
+				continue
+			}
+		}
+		if c.nonce == "" {
+			continue
+		}
+		return c, true
+	}
+	return nil, false
+}
+
+// parseAuthParams parses the comma separated name=value parameters of a
+// challenge, values being tokens or quoted strings
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " \t")
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+func quoteAuthParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Wrap sets base as the Base of DigestAuth, so it can be used with Stack
+func (t *DigestAuth) Wrap(base http.RoundTripper) http.RoundTripper {
+	t.Base = base
+	return t
+}
+
+func (t *DigestAuth) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
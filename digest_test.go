@@ -0,0 +1,125 @@
+package port
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestChallenge_RFC2617(t *testing.T) {
+	c, ok := parseDigestChallenge(http.Header{"Www-Authenticate": {`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`}})
+	require.True(t, ok)
+	c.nc = 1
+	auth := c.authorization("Mufasa", "Circle Of Life", "GET", "/dir/index.html", "0a4f113b")
+	params := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+	assert.Equal(t, "6629fae49393a05397450978507c4ef1", params["response"])
+	assert.Equal(t, "00000001", params["nc"])
+	assert.Equal(t, "auth", params["qop"])
+	assert.Equal(t, "5ccc069c403ebaf9f0171e9517f40e41", params["opaque"])
+}
+
+func TestDigestChallenge_Unsupported(t *testing.T) {
+	_, ok := parseDigestChallenge(http.Header{"Www-Authenticate": {`Basic realm="x"`}})
+	assert.False(t, ok)
+	_, ok = parseDigestChallenge(http.Header{"Www-Authenticate": {`Digest realm="x", nonce="n", qop="auth-int"`}})
+	assert.False(t, ok)
+	_, ok = parseDigestChallenge(http.Header{"Www-Authenticate": {`Digest realm="x", nonce="n", algorithm=SHA-512-256`}})
+	assert.False(t, ok)
+}
+
+// digestServer only accepts requests answering its challenge
+type digestServer struct {
+	username, password string
+
+	mu         sync.Mutex
+	challenges int
+	ncs        []string
+}
+
+func (d *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	const realm, nonce = "device", "abc123"
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	p := parseAuthParams(rest)
+	ha1 := md5hex(d.username + ":" + realm + ":" + d.password)
+	ha2 := md5hex(r.Method + ":" + p["uri"])
+	expected := md5hex(ha1 + ":" + nonce + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if scheme != "Digest" || p["nonce"] != nonce || p["uri"] != r.URL.RequestURI() || p["response"] != expected {
+		d.challenges++
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, nonce=%q, qop="auth", algorithm=MD5`, realm, nonce))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	d.ncs = append(d.ncs, p["nc"])
+	b, _ := io.ReadAll(r.Body)
+	_, _ = fmt.Fprintf(w, "hello %s", b)
+}
+
+func TestDigestAuth(t *testing.T) {
+	ds := &digestServer{username: "admin", password: "s3cret"}
+	s := httptest.NewServer(ds)
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewDigestAuth(c.Transport, "admin", "s3cret")
+
+	resp, err := c.Post(s.URL+"/api?x=1", "text/plain", strings.NewReader("device"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello device", string(b))
+
+	// the nonce is reused without a new challenge
+	resp, err = c.Get(s.URL + "/status")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Equal(t, 1, ds.challenges)
+	assert.Equal(t, []string{"00000001", "00000002"}, ds.ncs)
+}
+
+func TestDigestAuth_WrongPassword(t *testing.T) {
+	ds := &digestServer{username: "admin", password: "s3cret"}
+	s := httptest.NewServer(ds)
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewDigestAuth(c.Transport, "admin", "wrong")
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 2, ds.challenges)
+
+	// the nonce is sent up front, a second rejection is final
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 3, ds.challenges)
+}
@@ -0,0 +1,106 @@
+package port
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+)
+
+// DefaultRedactedHeaders are the headers hidden by Dumper when RedactHeaders
+// is nil
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+const redacted = "[REDACTED]"
+
+// Dumper is a Logger writing the requests sent and the responses received to
+// W in wire format, like httputil.DumpRequestOut and httputil.DumpResponse.
+// URLs are written with SafeURL. It is meant for troubleshooting: with
+// IncludeBody, response bodies are read in memory before being handed to the
+// caller, and request bodies are only dumped when they can be read again with
+// GetBody
+type Dumper struct {
+	W io.Writer
+	// IncludeBody dumps the request and response bodies as well
+	IncludeBody bool
+	// RedactHeaders lists the headers whose values are hidden, it defaults
+	// to DefaultRedactedHeaders
+	RedactHeaders []string
+
+	mu sync.Mutex // serializes writes to W
+}
+
+// LogRequest dumps the request about to be sent
+func (d *Dumper) LogRequest(req *http.Request) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = d.redact(req.Header)
+	r.URL = safeURL(req)
+	withBody := d.IncludeBody && req.Body != nil && req.Body != http.NoBody && req.GetBody != nil
+	if withBody {
+		body, err := req.GetBody()
+		if err != nil {
+			withBody = false
+		} else {
+			r.Body = body
+		}
+	}
+	b, err := httputil.DumpRequestOut(r, withBody)
+	if withBody {
+		_ = r.Body.Close()
+	}
+	if err != nil {
+		d.write("> unable to dump request: %v\n", err)
+		return
+	}
+	d.write("> request\n%s\n", b)
+}
+
+// LogResponse dumps the response received, or the transport error
+func (d *Dumper) LogResponse(resp *http.Response, err error, latency time.Duration) {
+	if err != nil {
+		d.write("< error after %s: %v\n", latency, err)
+		return
+	}
+	r := new(http.Response)
+	*r = *resp
+	r.Header = d.redact(resp.Header)
+	b, err := httputil.DumpResponse(r, d.IncludeBody)
+	// DumpResponse replaced the body of the copy with one reading from memory
+	resp.Body = r.Body
+	if err != nil {
+		d.write("< unable to dump response: %v\n", err)
+		return
+	}
+	d.write("< response after %s\n%s\n", latency, b)
+}
+
+func (d *Dumper) write(format string, args ...any) {
+	if d.W == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, _ = fmt.Fprintf(d.W, format, args...)
+}
+
+// redact returns a copy of h with the values of the sensitive headers hidden
+func (d *Dumper) redact(h http.Header) http.Header {
+	names := d.RedactHeaders
+	if names == nil {
+		names = DefaultRedactedHeaders
+	}
+	h = h.Clone()
+	for _, name := range names {
+		key := http.CanonicalHeaderKey(name)
+		if vs, ok := h[key]; ok {
+			h[key] = make([]string, len(vs))
+			for i := range vs {
+				h[key][i] = redacted
+			}
+		}
+	}
+	return h
+}
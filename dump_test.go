@@ -0,0 +1,81 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDump(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write(append([]byte("echo: "), b...))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var buf bytes.Buffer
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithRequestModifier(ChainModifiers(BearerToken("t0ps3cret"), SetHeaders(http.Header{"X-Injected": {"yes"}}))),
+		WithDump(&buf, true),
+	)
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// the caller still gets the whole body
+	assert.Equal(t, "echo: hello", string(b))
+
+	dump := buf.String()
+	assert.Contains(t, dump, "POST / HTTP/1.1")
+	assert.Contains(t, dump, "X-Injected: yes")
+	assert.Contains(t, dump, "Authorization: [REDACTED]")
+	assert.Contains(t, dump, "Set-Cookie: [REDACTED]")
+	assert.NotContains(t, dump, "t0ps3cret")
+	assert.NotContains(t, dump, "session=abc")
+	assert.Contains(t, dump, "\r\n\r\nhello")
+	assert.Contains(t, dump, "echo: hello")
+}
+
+func TestDumper_NoBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "response body")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var buf bytes.Buffer
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithRequestModifier(SetHeaders(http.Header{"X-Api-Key": {"k3y"}})),
+		WithLogger(&Dumper{W: &buf, RedactHeaders: []string{"x-api-key"}}),
+	)
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("request body"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "response body", string(b))
+
+	dump := buf.String()
+	assert.Contains(t, dump, "X-Api-Key: [REDACTED]")
+	assert.NotContains(t, dump, "k3y")
+	assert.NotContains(t, dump, "request body")
+	assert.NotContains(t, dump, "response body")
+}
@@ -0,0 +1,139 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ETagEntry is a response kept by an ETagStore
+type ETagEntry struct {
+	ETag   string
+	Header http.Header
+	Body   []byte
+}
+
+// ETagStore keeps the last response received with an ETag per resource, see
+// ConditionalGet. Implementations must be safe for concurrent use
+type ETagStore interface {
+	Get(key string) (*ETagEntry, bool)
+	Set(key string, entry *ETagEntry)
+}
+
+// NewMemoryETagStore returns an empty in-memory ETagStore
+func NewMemoryETagStore() *MemoryETagStore {
+	return &MemoryETagStore{entries: make(map[string]*ETagEntry)}
+}
+
+// MemoryETagStore is an ETagStore keeping responses in a map, without eviction
+type MemoryETagStore struct {
+	mu      sync.RWMutex
+	entries map[string]*ETagEntry
+}
+
+// Get returns the response stored under key
+func (s *MemoryETagStore) Get(key string) (*ETagEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[key]
+	return e, ok
+}
+
+// Set stores entry under key
+func (s *MemoryETagStore) Set(key string, entry *ETagEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry
+}
+
+// ETagOptions configures ConditionalGet
+type ETagOptions struct {
+	// KeepNotModified hands the 304 responses to the caller as is, rather than
+	// the stored response
+	KeepNotModified bool
+	// MaxBodySize bounds the bodies stored, DefaultMaxBodySize by default.
+	// Larger responses are not stored
+	MaxBodySize int64
+}
+
+// ConditionalGet returns a RequestModifier and a ResponseModifier, to be used
+// together, making GET requests conditional: the modifier sends the ETag
+// stored for the request URL in If-None-Match, the response modifier stores
+// the 200 responses carrying an ETag and, unless KeepNotModified is set, turns
+// a 304 into a 200 serving the stored body. Requests already carrying
+// If-None-Match are left alone
+func ConditionalGet(store ETagStore, opts ETagOptions) (RequestModifier, ResponseModifier) {
+	maxBody := opts.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodySize
+	}
+
+	reqMod := RequestModifierFunc(func(req *http.Request) error {
+		if !isGet(req) || req.Header.Get("If-None-Match") != "" {
+			return nil
+		}
+		if e, ok := store.Get(req.URL.String()); ok {
+			req.Header.Set("If-None-Match", e.ETag)
+		}
+		return nil
+	})
+
+	respMod := ResponseModifierFunc(func(resp *http.Response) error {
+		req := resp.Request
+		if req == nil || !isGet(req) {
+			return nil
+		}
+		key := req.URL.String()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			etag := resp.Header.Get("ETag")
+			if etag == "" || resp.Body == nil {
+				return nil
+			}
+			b, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+			if err != nil {
+				_ = resp.Body.Close()
+				return errors.Wrap(err, "unable to read response body")
+			}
+			if int64(len(b)) > maxBody {
+				resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+				return nil
+			}
+			_ = resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(b))
+			store.Set(key, &ETagEntry{ETag: etag, Header: resp.Header.Clone(), Body: b})
+		case http.StatusNotModified:
+			e, ok := store.Get(key)
+			if !ok || opts.KeepNotModified || req.Header.Get("If-None-Match") != e.ETag {
+				return nil
+			}
+			discardResponse(resp)
+			// the 304 carries the up to date metadata
+			header := e.Header.Clone()
+			if header == nil {
+				// the entry of an application store may have no header
+				header = http.Header{}
+			}
+			for k, v := range resp.Header {
+				header[k] = v
+			}
+			store.Set(key, &ETagEntry{ETag: e.ETag, Header: header, Body: e.Body})
+			resp.StatusCode = http.StatusOK
+			resp.Status = "200 OK"
+			resp.Header = header.Clone()
+			resp.Header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+			resp.ContentLength = int64(len(e.Body))
+			resp.Body = io.NopCloser(bytes.NewReader(e.Body))
+		}
+		return nil
+	})
+	return reqMod, respMod
+}
+
+func isGet(req *http.Request) bool {
+	return req.Method == "" || req.Method == http.MethodGet
+}
@@ -0,0 +1,132 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func etagServer(calls, notModified *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("X-Version", "2")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("X-Version", "1")
+		_, _ = io.WriteString(w, "resource "+r.URL.Path)
+	}))
+}
+
+func TestConditionalGet(t *testing.T) {
+	var calls, notModified int32
+	s := etagServer(&calls, &notModified)
+
+	defer func() {
+		s.Close()
+	}()
+
+	store := NewMemoryETagStore()
+	reqMod, respMod := ConditionalGet(store, ETagOptions{})
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithRequestModifier(reqMod), WithResponseModifier(respMod))
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := c.Get(s.URL + path)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp, string(b)
+	}
+
+	// a fresh 200 stores the ETag
+	resp, body := get("/a")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "resource /a", body)
+	e, ok := store.Get(s.URL + "/a")
+	require.True(t, ok)
+	assert.Equal(t, `"v1"`, e.ETag)
+
+	// the 304 is served from the store, with the refreshed headers
+	resp, body = get("/a")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "resource /a", body)
+	assert.Equal(t, "2", resp.Header.Get("X-Version"))
+	assert.Equal(t, int64(len("resource /a")), resp.ContentLength)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+
+	// another URL has no ETag yet
+	_, body = get("/b")
+	assert.Equal(t, "resource /b", body)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+}
+
+func TestConditionalGet_KeepNotModified(t *testing.T) {
+	var calls, notModified int32
+	s := etagServer(&calls, &notModified)
+
+	defer func() {
+		s.Close()
+	}()
+
+	reqMod, respMod := ConditionalGet(NewMemoryETagStore(), ETagOptions{KeepNotModified: true})
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithRequestModifier(reqMod), WithResponseModifier(respMod))
+
+	for _, status := range []int{http.StatusOK, http.StatusNotModified} {
+		resp, err := c.Get(s.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode)
+	}
+}
+
+// headerlessStore is an application store keeping no headers
+type headerlessStore struct {
+	set []*ETagEntry
+}
+
+func (s *headerlessStore) Get(key string) (*ETagEntry, bool) {
+	return &ETagEntry{ETag: `"v1"`, Body: []byte("stored body")}, true
+}
+
+func (s *headerlessStore) Set(key string, e *ETagEntry) {
+	s.set = append(s.set, e)
+}
+
+func TestConditionalGet_EntryWithoutHeader(t *testing.T) {
+	var calls, notModified int32
+	s := etagServer(&calls, &notModified)
+
+	defer func() {
+		s.Close()
+	}()
+
+	store := &headerlessStore{}
+	reqMod, respMod := ConditionalGet(store, ETagOptions{})
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithRequestModifier(reqMod), WithResponseModifier(respMod))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "stored body", string(b))
+	assert.Equal(t, "2", resp.Header.Get("X-Version"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+	require.Len(t, store.set, 1)
+	assert.Equal(t, "2", store.set[0].Header.Get("X-Version"))
+}
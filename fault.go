@@ -0,0 +1,131 @@
+package port
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInjectedFault is the connection error returned by FaultInjector
+var ErrInjectedFault = errors.New("injected fault")
+
+// NewFaultInjector returns a roundtripper injecting faults chosen from a
+// random source seeded with seed, so a sequence of requests always gets the
+// same faults
+func NewFaultInjector(baseTransport http.RoundTripper, seed int64) *FaultInjector {
+	return &FaultInjector{
+		Base: baseTransport,
+		Seed: seed,
+	}
+}
+
+// FaultInjector is a roundtripper meant for resilience tests. Every request is
+// delayed with probability LatencyRate, then fails with ErrInjectedFault with
+// probability ErrorRate, or else gets one of Statuses, without reaching Base,
+// with probability StatusRate. Probabilities range from 0 to 1. Delays honor
+// the request context
+type FaultInjector struct {
+	Base http.RoundTripper
+	// Seed initializes the random source on the first request
+	Seed int64
+	// LatencyRate is the probability of delaying a request
+	LatencyRate float64
+	// MinLatency and MaxLatency bound the injected delay, drawn uniformly
+	MinLatency time.Duration
+	MaxLatency time.Duration
+	// ErrorRate is the probability of failing a request with ErrInjectedFault
+	ErrorRate float64
+	// StatusRate is the probability of answering with a status of Statuses
+	StatusRate float64
+	// Statuses are the injected status codes, drawn uniformly. It defaults to
+	// 503 Service Unavailable
+	Statuses []int
+
+	mu  sync.Mutex // guards rnd
+	rnd *rand.Rand
+}
+
+// fault is what FaultInjector does to a single request
+type fault struct {
+	delay  time.Duration
+	err    bool
+	status int
+}
+
+// RoundTrip injects the faults drawn for the request
+func (f *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	ft := f.draw()
+	if ft.delay > 0 {
+		select {
+		case <-req.Context().Done():
+			closeBody(req)
+			return nil, req.Context().Err()
+		case <-clockFrom(req.Context()).After(ft.delay):
+		}
+	}
+	if ft.err {
+		closeBody(req)
+		return nil, ErrInjectedFault
+	}
+	if ft.status != 0 {
+		closeBody(req)
+		return &http.Response{
+			Status:     strconv.Itoa(ft.status) + " " + http.StatusText(ft.status),
+			StatusCode: ft.status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return f.base().RoundTrip(req)
+}
+
+// draw picks the faults of a request, every decision is drawn at once so the
+// sequence only depends on the seed and the number of requests
+func (f *FaultInjector) draw() fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rnd == nil {
+		f.rnd = rand.New(rand.NewSource(f.Seed))
+	}
+	latency, delay, failure, status, pick := f.rnd.Float64(), f.rnd.Float64(), f.rnd.Float64(), f.rnd.Float64(), f.rnd.Float64()
+
+	var ft fault
+	if latency < f.LatencyRate {
+		ft.delay = f.MinLatency
+		if f.MaxLatency > f.MinLatency {
+			ft.delay += time.Duration(delay * float64(f.MaxLatency-f.MinLatency))
+		}
+	}
+	switch {
+	case failure < f.ErrorRate:
+		ft.err = true
+	case status < f.StatusRate:
+		statuses := f.Statuses
+		if len(statuses) == 0 {
+			statuses = []int{http.StatusServiceUnavailable}
+		}
+		ft.status = statuses[int(pick*float64(len(statuses)))]
+	}
+	return ft
+}
+
+// Wrap sets base as the Base of FaultInjector, so it can be used with Stack
+func (f *FaultInjector) Wrap(base http.RoundTripper) http.RoundTripper {
+	f.Base = base
+	return f
+}
+
+func (f *FaultInjector) base() http.RoundTripper {
+	if f.Base != nil {
+		return f.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,78 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func faultMix(t *testing.T, seed int64, n int) map[string]int {
+	f := NewFaultInjector(&Recorder{}, seed)
+	f.ErrorRate = 0.2
+	f.StatusRate = 0.25
+	f.Statuses = []int{http.StatusInternalServerError, http.StatusTooManyRequests}
+
+	mix := make(map[string]int)
+	for i := 0; i < n; i++ {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		require.NoError(t, err)
+		resp, err := f.RoundTrip(req)
+		if err != nil {
+			require.True(t, errors.Is(err, ErrInjectedFault))
+			mix["error"]++
+			continue
+		}
+		mix[http.StatusText(resp.StatusCode)]++
+	}
+	return mix
+}
+
+func TestFaultInjector_Mix(t *testing.T) {
+	const n = 2000
+	mix := faultMix(t, 42, n)
+
+	// same seed, same faults
+	assert.Equal(t, mix, faultMix(t, 42, n))
+
+	assert.InDelta(t, 0.2*n, mix["error"], 0.05*n)
+	// statuses are only drawn for requests not failed
+	injected := mix[http.StatusText(http.StatusInternalServerError)] + mix[http.StatusText(http.StatusTooManyRequests)]
+	assert.InDelta(t, 0.8*0.25*n, injected, 0.05*n)
+	assert.InDelta(t, mix[http.StatusText(http.StatusInternalServerError)], mix[http.StatusText(http.StatusTooManyRequests)], 0.05*n)
+	assert.Equal(t, n, mix["error"]+injected+mix[http.StatusText(http.StatusOK)])
+}
+
+func TestFaultInjector_Latency(t *testing.T) {
+	f := NewFaultInjector(&Recorder{}, 1)
+	f.LatencyRate = 1
+	f.MinLatency = 20 * time.Millisecond
+	f.MaxLatency = 30 * time.Millisecond
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	st := time.Now()
+	resp, err := f.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, time.Since(st) >= 20*time.Millisecond)
+}
+
+func TestFaultInjector_Latency_Canceled(t *testing.T) {
+	f := NewFaultInjector(&Recorder{}, 1)
+	f.LatencyRate = 1
+	f.MinLatency = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
+	require.NoError(t, err)
+	st := time.Now()
+	_, err = f.RoundTrip(req)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.WithinDuration(t, time.Now(), st, time.Second)
+}
@@ -0,0 +1,50 @@
+package port
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedFor returns a RequestModifier for forwarding proxies, sending an
+// incoming request on: the client IP returned by clientIP is appended to the
+// X-Forwarded-For chain, and X-Forwarded-Proto and X-Forwarded-Host are set
+// from the original request, its TLS state and Host. clientIP defaults to the
+// request RemoteAddr, it may return an address with a port, or a bracketed
+// IPv6; an empty IP leaves the chain untouched
+func ForwardedFor(clientIP func(req *http.Request) string) RequestModifier {
+	if clientIP == nil {
+		clientIP = func(req *http.Request) string { return req.RemoteAddr }
+	}
+	return RequestModifierFunc(func(req *http.Request) error {
+		if ip := forwardedIP(clientIP(req)); ip != "" {
+			chain := strings.Join(req.Header.Values("X-Forwarded-For"), ", ")
+			if chain != "" {
+				ip = chain + ", " + ip
+			}
+			req.Header.Set("X-Forwarded-For", ip)
+		}
+
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+		req.Header.Set("X-Forwarded-Proto", proto)
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		req.Header.Set("X-Forwarded-Host", host)
+		return nil
+	})
+}
+
+// forwardedIP returns the IP of addr without port nor brackets, as written in
+// X-Forwarded-For
+func forwardedIP(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
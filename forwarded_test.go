@@ -0,0 +1,45 @@
+package port
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedFor(t *testing.T) {
+	// an incoming request received over TLS, already forwarded once
+	req, err := http.NewRequest("GET", "http://backend.internal/api", nil)
+	require.NoError(t, err)
+	req.Host = "www.example.com"
+	req.TLS = &tls.ConnectionState{}
+	req.RemoteAddr = "192.0.2.10:51234"
+	req.Header.Add("X-Forwarded-For", "203.0.113.1")
+	req.Header.Add("X-Forwarded-For", "198.51.100.7, 10.0.0.1")
+
+	require.NoError(t, ForwardedFor(nil).Intercept(req))
+	assert.Equal(t, []string{"203.0.113.1, 198.51.100.7, 10.0.0.1, 192.0.2.10"}, req.Header.Values("X-Forwarded-For"))
+	assert.Equal(t, "https", req.Header.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "www.example.com", req.Header.Get("X-Forwarded-Host"))
+}
+
+func TestForwardedFor_IPv6(t *testing.T) {
+	for _, addr := range []string{"[2001:db8::1]:8080", "[2001:db8::1]", "2001:db8::1"} {
+		req, err := http.NewRequest("GET", "http://backend.internal:8080/", nil)
+		require.NoError(t, err)
+		req.Host = ""
+
+		require.NoError(t, ForwardedFor(func(*http.Request) string { return addr }).Intercept(req))
+		assert.Equal(t, "2001:db8::1", req.Header.Get("X-Forwarded-For"), addr)
+		assert.Equal(t, "http", req.Header.Get("X-Forwarded-Proto"))
+		assert.Equal(t, "backend.internal:8080", req.Header.Get("X-Forwarded-Host"))
+	}
+
+	// no client IP, no chain
+	req, err := http.NewRequest("GET", "http://backend.internal/", nil)
+	require.NoError(t, err)
+	require.NoError(t, ForwardedFor(nil).Intercept(req))
+	assert.Empty(t, req.Header.Values("X-Forwarded-For"))
+}
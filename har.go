@@ -0,0 +1,266 @@
+package port
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewHARRecorder returns a roundtripper recording the traffic going through
+// baseTransport, written to w in HAR format on Close
+func NewHARRecorder(baseTransport http.RoundTripper, w io.Writer) *HARRecorder {
+	return &HARRecorder{
+		Base: baseTransport,
+		W:    w,
+	}
+}
+
+// HARRecorder records every request sent to Base and its response, then
+// writes them to W as an HTTP Archive (HAR 1.2) when closed, e.g. to share a
+// reproduction of an issue. Place it below the interceptor to record the
+// modified requests. Transport errors are recorded in the _error field of
+// their entry. Requests sent after Close are not recorded
+type HARRecorder struct {
+	Base http.RoundTripper
+	W    io.Writer
+	// CaptureBodies records the request and response bodies as well. The
+	// request body is only captured when it can be read again with GetBody,
+	// the response body as the caller reads it
+	CaptureBodies bool
+	// MaxBodySize bounds the bytes captured per body, DefaultMaxBodySize by
+	// default
+	MaxBodySize int64
+
+	mu      sync.Mutex // guards the fields below and the captured bodies
+	entries []*harEntry
+	closed  bool
+}
+
+// RoundTrip sends the request to Base and records the exchange
+func (h *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	clk := clockFrom(req.Context())
+	entry := &harEntry{
+		StartedDateTime: clk.Now(),
+		Request:         h.harRequest(req),
+		Cache:           struct{}{},
+	}
+	h.mu.Lock()
+	if !h.closed {
+		h.entries = append(h.entries, entry)
+	}
+	h.mu.Unlock()
+
+	start := clk.Now()
+	res, err := h.base().RoundTrip(req)
+	wait := float64(clk.Now().Sub(start)) / float64(time.Millisecond)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry.Time = wait
+	entry.Timings = harTimings{Send: 0, Wait: wait, Receive: 0}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = harResponse{Headers: []harNameValue{}, Cookies: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+		return res, err
+	}
+	entry.Response = harResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Headers:     harHeaders(res.Header),
+		Cookies:     []harNameValue{},
+		Content:     harContent{MimeType: res.Header.Get("Content-Type")},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    res.ContentLength,
+	}
+	if h.CaptureBodies && res.Body != nil && res.Body != http.NoBody {
+		res.Body = &harBody{ReadCloser: res.Body, h: h, content: &entry.Response.Content, limit: h.maxBodySize()}
+	}
+	return res, nil
+}
+
+// Close writes the recorded entries to W
+func (h *HARRecorder) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	var doc harDocument
+	doc.Log.Version = "1.2"
+	doc.Log.Creator = harCreator{Name: "port", Version: "1.0"}
+	doc.Log.Entries = h.entries
+	if doc.Log.Entries == nil {
+		doc.Log.Entries = []*harEntry{}
+	}
+	for _, e := range doc.Log.Entries {
+		e.Response.Content.Text = string(e.Response.Content.body)
+	}
+	enc := json.NewEncoder(h.W)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrap(err, "unable to write har")
+	}
+	return nil
+}
+
+// Wrap sets base as the Base of HARRecorder, so it can be used with Stack
+func (h *HARRecorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	h.Base = base
+	return h
+}
+
+func (h *HARRecorder) harRequest(req *http.Request) harRequest {
+	r := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Headers:     harHeaders(req.Header),
+		QueryString: []harNameValue{},
+		Cookies:     []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			r.QueryString = append(r.QueryString, harNameValue{Name: name, Value: v})
+		}
+	}
+	for _, c := range req.Cookies() {
+		r.Cookies = append(r.Cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+	if h.CaptureBodies && req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(io.LimitReader(body, h.maxBodySize()))
+			_ = body.Close()
+			r.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(b)}
+		}
+	}
+	return r
+}
+
+func (h *HARRecorder) maxBodySize() int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+func (h *HARRecorder) base() http.RoundTripper {
+	if h.Base != nil {
+		return h.Base
+	}
+	return http.DefaultTransport
+}
+
+// harBody captures the response body as it is read
+type harBody struct {
+	io.ReadCloser
+	h       *HARRecorder
+	content *harContent
+	limit   int64
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.h.mu.Lock()
+	b.content.Size += int64(n)
+	if room := b.limit - int64(len(b.content.body)); room > 0 {
+		b.content.body = append(b.content.body, p[:min(int64(n), room)]...)
+	}
+	b.h.mu.Unlock()
+	return n, err
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, harNameValue{Name: name, Value: v})
+		}
+	}
+	return headers
+}
+
+type harDocument struct {
+	Log struct {
+		Version string      `json:"version"`
+		Creator harCreator  `json:"creator"`
+		Entries []*harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+
+	body []byte // captured so far
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -0,0 +1,115 @@
+package port
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testHAR struct {
+	Log struct {
+		Version string `json:"version"`
+		Entries []struct {
+			Time    float64 `json:"time"`
+			Request struct {
+				Method   string         `json:"method"`
+				URL      string         `json:"url"`
+				Headers  []harNameValue `json:"headers"`
+				PostData *harPostData   `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Content struct {
+					Size int64  `json:"size"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"response"`
+			Error string `json:"_error"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func TestHARRecorder(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = io.WriteString(w, "response body")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var buf bytes.Buffer
+	har := NewHARRecorder(nil, &buf)
+	har.CaptureBodies = true
+	har.MaxBodySize = 8
+	c := s.Client()
+	c.Transport = Stack(c.Transport,
+		NewInterceptor(nil, WithRequestModifier(SetHeaders(http.Header{"X-Injected": {"yes"}}))),
+		har,
+	)
+
+	resp, err := c.Get(s.URL + "/path?q=1")
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "response body", string(b))
+
+	resp, err = c.Post(s.URL, "text/plain", strings.NewReader("request body"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	require.NoError(t, har.Close())
+	var doc testHAR
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	assert.Equal(t, "1.2", doc.Log.Version)
+	require.Len(t, doc.Log.Entries, 2)
+
+	get := doc.Log.Entries[0]
+	assert.Equal(t, "GET", get.Request.Method)
+	assert.Equal(t, s.URL+"/path?q=1", get.Request.URL)
+	assert.Contains(t, get.Request.Headers, harNameValue{Name: "X-Injected", Value: "yes"})
+	assert.Equal(t, http.StatusAccepted, get.Response.Status)
+	assert.Equal(t, int64(13), get.Response.Content.Size)
+	assert.Equal(t, "response", get.Response.Content.Text)
+	assert.True(t, get.Time >= 0)
+
+	post := doc.Log.Entries[1]
+	require.NotNil(t, post.Request.PostData)
+	assert.Equal(t, "request ", post.Request.PostData.Text)
+	// the body was not read
+	assert.Empty(t, post.Response.Content.Text)
+
+	// closed, nothing is recorded anymore
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Len(t, har.entries, 2)
+}
+
+func TestHARRecorder_Error(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.Close()
+
+	var buf bytes.Buffer
+	har := NewHARRecorder(nil, &buf)
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	_, err = har.RoundTrip(req)
+	require.Error(t, err)
+
+	require.NoError(t, har.Close())
+	var doc testHAR
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Log.Entries, 1)
+	assert.NotEmpty(t, doc.Log.Entries[0].Error)
+	assert.Equal(t, 0, doc.Log.Entries[0].Response.Status)
+}
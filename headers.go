@@ -0,0 +1,235 @@
+package port
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// UserAgent returns a RequestModifier replacing the User-Agent header with ua
+func UserAgent(ua string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		req.Header.Set("User-Agent", ua)
+		return nil
+	})
+}
+
+// AppendUserAgent returns a RequestModifier appending token to the User-Agent
+// header, separated by a space. When the request has no User-Agent the header
+// is set to token, so it is not replaced by the Go default later on
+func AppendUserAgent(token string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		ua := token
+		if existing := req.Header.Get("User-Agent"); existing != "" {
+			ua = existing + " " + token
+		}
+		req.Header.Set("User-Agent", ua)
+		return nil
+	})
+}
+
+// SetHeaders returns a RequestModifier setting every header of h, replacing
+// the values already present. When several modifiers set the same header, the
+// last one applied wins
+func SetHeaders(h http.Header) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		setHeaders(req, h)
+		return nil
+	})
+}
+
+// AddHeaders returns a RequestModifier appending the values of h to the
+// request headers, so values added by several modifiers accumulate
+func AddHeaders(h http.Header) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for k, vs := range h {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		return nil
+	})
+}
+
+// AddHeaderUnique returns a RequestModifier appending value to the key header
+// unless the request already carries it, either as a value of its own or as
+// an element of a comma-separated list. Values are compared case-sensitively,
+// see AddHeaderUniqueFold otherwise
+func AddHeaderUnique(key, value string) RequestModifier {
+	return addHeaderUnique(key, value, func(a, b string) bool { return a == b })
+}
+
+// AddHeaderUniqueFold is like AddHeaderUnique but compares values
+// case-insensitively, e.g. for tokens like Accept-Encoding codings
+func AddHeaderUniqueFold(key, value string) RequestModifier {
+	return addHeaderUnique(key, value, strings.EqualFold)
+}
+
+func addHeaderUnique(key, value string, equal func(a, b string) bool) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for _, v := range req.Header.Values(key) {
+			if equal(v, value) {
+				return nil
+			}
+			for _, elem := range strings.Split(v, ",") {
+				if equal(strings.TrimSpace(elem), value) {
+					return nil
+				}
+			}
+		}
+		req.Header.Add(key, value)
+		return nil
+	})
+}
+
+// SetHeadersFunc returns a RequestModifier setting the headers computed by fn
+// for every request, e.g. out of values carried by the request context. It
+// follows the SetHeaders precedence
+func SetHeadersFunc(fn func(req *http.Request) (http.Header, error)) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		h, err := fn(req)
+		if err != nil {
+			return errors.Wrap(err, "unable to compute headers")
+		}
+		setHeaders(req, h)
+		return nil
+	})
+}
+
+func setHeaders(req *http.Request, h http.Header) {
+	for k, vs := range h {
+		req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+	}
+}
+
+// DefaultHeaders returns a RequestModifier setting the headers of h missing
+// from the request, so values set by the caller win. A header present with an
+// empty value counts as set
+func DefaultHeaders(h http.Header) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for k, vs := range h {
+			k = http.CanonicalHeaderKey(k)
+			if _, ok := req.Header[k]; ok {
+				continue
+			}
+			req.Header[k] = append([]string(nil), vs...)
+		}
+		return nil
+	})
+}
+
+// hopByHopHeaders are meaningful for a single connection only, RFC 7230
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// StripHeaders returns a RequestModifier removing the given headers. Names
+// are matched case-insensitively, even for keys not in canonical form
+func StripHeaders(names ...string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for k := range req.Header {
+			if containsFold(names, k) {
+				delete(req.Header, k)
+			}
+		}
+		return nil
+	})
+}
+
+// RawHeader returns a RequestModifier setting the name header to value with
+// name used verbatim as the key of req.Header, bypassing the canonicalization
+// of Header.Set, so HTTP/1.x servers picky about casing receive e.g. X-API-Key
+// rather than X-Api-Key. The values of the header under any other casing are
+// removed. A header set this way is only read back with its exact key, not with
+// Header.Get. HTTP/2 and HTTP/3 lowercase every header name on the wire, the
+// casing only survives over HTTP/1.x
+func RawHeader(name, value string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for k := range req.Header {
+			if strings.EqualFold(k, name) {
+				delete(req.Header, k)
+			}
+		}
+		req.Header[name] = []string{value}
+		return nil
+	})
+}
+
+// StripHopByHop returns a RequestModifier removing the hop-by-hop headers
+// (Connection, Keep-Alive, Proxy-*, TE, Trailer, Transfer-Encoding, Upgrade)
+// as well as the headers listed in Connection
+func StripHopByHop() RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		names := append([]string(nil), hopByHopHeaders...)
+		for k, vs := range req.Header {
+			if !strings.EqualFold(k, "Connection") {
+				continue
+			}
+			for _, v := range vs {
+				for _, name := range strings.Split(v, ",") {
+					if name = strings.TrimSpace(name); name != "" {
+						names = append(names, name)
+					}
+				}
+			}
+		}
+		for k := range req.Header {
+			if containsFold(names, k) || (len(k) > 6 && strings.EqualFold(k[:6], "Proxy-")) {
+				delete(req.Header, k)
+			}
+		}
+		return nil
+	})
+}
+
+// ErrMissingHeader is matched, with errors.Is, by the *MissingHeaderError
+// returned by RequireHeaders
+var ErrMissingHeader = errors.New("missing header")
+
+// MissingHeaderError is returned by RequireHeaders for requests lacking a
+// mandatory header
+type MissingHeaderError struct {
+	Header string
+}
+
+func (e *MissingHeaderError) Error() string {
+	return "missing header " + e.Header
+}
+
+// Is makes errors.Is match ErrMissingHeader
+func (e *MissingHeaderError) Is(target error) bool {
+	return target == ErrMissingHeader
+}
+
+// RequireHeaders returns a RequestModifier rejecting the requests lacking one
+// of the headers names, with a *MissingHeaderError naming the first one
+// missing. A header set to an empty or blank value counts as missing, see
+// RequireHeadersAllowEmpty otherwise
+func RequireHeaders(names ...string) RequestModifier {
+	return requireHeaders(names, false)
+}
+
+// RequireHeadersAllowEmpty is like RequireHeaders, but a header present with
+// an empty value is accepted
+func RequireHeadersAllowEmpty(names ...string) RequestModifier {
+	return requireHeaders(names, true)
+}
+
+func requireHeaders(names []string, allowEmpty bool) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		for _, name := range names {
+			vs := req.Header.Values(name)
+			if len(vs) == 0 || (!allowEmpty && strings.TrimSpace(strings.Join(vs, "")) == "") {
+				return &MissingHeaderError{Header: http.CanonicalHeaderKey(name)}
+			}
+		}
+		return nil
+	})
+}
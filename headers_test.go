@@ -0,0 +1,277 @@
+package port
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAgent(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "curl/8.0")
+
+	require.NoError(t, UserAgent("port/1.0").Intercept(req))
+	assert.Equal(t, "port/1.0", req.Header.Get("User-Agent"))
+}
+
+func TestAppendUserAgent(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "Go-http-client/1.1")
+
+	mod := AppendUserAgent("port/1.0")
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "Go-http-client/1.1 port/1.0", req.Header.Get("User-Agent"))
+
+	// the modifier is reusable
+	req2, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, mod.Intercept(req2))
+	assert.Equal(t, "port/1.0", req2.Header.Get("User-Agent"))
+}
+
+func TestAppendUserAgent_Empty(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, AppendUserAgent("port/1.0"))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "port/1.0", received)
+}
+
+func TestSetHeaders(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("X-Kept", "kept")
+
+	mod := SetHeaders(http.Header{"accept": {"application/json"}, "X-Tenant": {"a", "b"}})
+	require.NoError(t, mod.Intercept(req))
+
+	assert.Equal(t, []string{"application/json"}, req.Header["Accept"])
+	assert.Equal(t, []string{"a", "b"}, req.Header["X-Tenant"])
+	assert.Equal(t, "kept", req.Header.Get("X-Kept"))
+}
+
+func TestAddHeaders(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/html")
+
+	mod := ChainModifiers(
+		AddHeaders(http.Header{"Accept": {"application/json"}}),
+		AddHeaders(http.Header{"accept": {"text/plain"}}),
+	)
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, []string{"text/html", "application/json", "text/plain"}, req.Header["Accept"])
+}
+
+func TestAddHeaderUnique(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	mod := ChainModifiers(
+		AddHeaderUnique("Accept-Encoding", "gzip"),
+		AddHeaderUnique("Accept-Encoding", "gzip"),
+		AddHeaderUnique("Accept-Encoding", "br"),
+		AddHeaderUnique("Accept-Encoding", "GZIP"),
+	)
+	require.NoError(t, mod.Intercept(req))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, []string{"gzip", "br", "GZIP"}, req.Header.Values("Accept-Encoding"))
+}
+
+func TestAddHeaderUniqueFold(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "deflate, GZIP")
+
+	require.NoError(t, AddHeaderUniqueFold("Accept-Encoding", "gzip").Intercept(req))
+	require.NoError(t, AddHeaderUniqueFold("Accept-Encoding", "br").Intercept(req))
+	assert.Equal(t, []string{"deflate, GZIP", "br"}, req.Header.Values("Accept-Encoding"))
+}
+
+func TestSetHeadersFunc(t *testing.T) {
+	type tenantKey struct{}
+	mod := SetHeadersFunc(func(req *http.Request) (http.Header, error) {
+		tenant, ok := req.Context().Value(tenantKey{}).(string)
+		if !ok {
+			return nil, errors.New("no tenant")
+		}
+		return http.Header{"X-Tenant": {tenant}}, nil
+	})
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), tenantKey{}, "acme"))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "acme", req.Header.Get("X-Tenant"))
+
+	req, err = http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	err = mod.Intercept(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no tenant")
+	assert.Empty(t, req.Header.Get("X-Tenant"))
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	var received http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, DefaultHeaders(http.Header{
+		"accept":       {"application/json"},
+		"Content-Type": {"application/json"},
+		"User-Agent":   {"port/1.0"},
+		"X-Empty":      {"default"},
+	}))
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("accept", "text/csv")
+	req.Header.Set("X-Empty", "")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"text/csv"}, received["Accept"])
+	assert.Equal(t, "application/json", received.Get("Content-Type"))
+	assert.Equal(t, "port/1.0", received.Get("User-Agent"))
+	assert.Equal(t, []string{""}, received["X-Empty"])
+}
+
+func TestStripHeaders(t *testing.T) {
+	rec := &Recorder{}
+	it := NewRequestInterceptor(rec, StripHeaders("x-internal-token", "COOKIE"))
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Internal-Token", "secret")
+	req.Header["cookie"] = []string{"a=b"}
+	req.Header.Set("Accept", "*/*")
+	_, err = it.RoundTrip(req)
+	require.NoError(t, err)
+
+	sent := rec.Requests()[0].Header
+	assert.Equal(t, http.Header{"Accept": {"*/*"}}, sent)
+	assert.Equal(t, "secret", req.Header.Get("X-Internal-Token"))
+	assert.Equal(t, []string{"a=b"}, req.Header["cookie"])
+}
+
+func TestStripHopByHop(t *testing.T) {
+	rec := &Recorder{}
+	it := NewRequestInterceptor(rec, StripHopByHop())
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	for _, h := range []string{"Connection", "Keep-Alive", "Proxy-Authorization", "Proxy-Connection", "Te", "Upgrade", "X-Custom-Hop", "Accept"} {
+		req.Header.Set(h, "value")
+	}
+	req.Header.Set("Connection", "keep-alive, x-custom-hop")
+	_, err = it.RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.Header{"Accept": {"value"}}, rec.Requests()[0].Header)
+	assert.Equal(t, "value", req.Header.Get("Proxy-Authorization"))
+	assert.Equal(t, "value", req.Header.Get("X-Custom-Hop"))
+}
+
+func TestRequireHeaders(t *testing.T) {
+	newReq := func(h http.Header) *http.Request {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		require.NoError(t, err)
+		req.Header = h
+		return req
+	}
+
+	// all present
+	h := http.Header{"X-Tenant": {"acme"}, "X-Request-Id": {"42"}}
+	assert.NoError(t, RequireHeaders("x-tenant", "X-Request-ID").Intercept(newReq(h)))
+	assert.NoError(t, RequireHeadersAllowEmpty("x-tenant", "X-Request-ID").Intercept(newReq(h)))
+
+	// the first missing one is named
+	err := RequireHeaders("X-Tenant", "X-Api-Key", "X-Other").Intercept(newReq(h))
+	assert.True(t, errors.Is(err, ErrMissingHeader))
+	var missing *MissingHeaderError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, "X-Api-Key", missing.Header)
+	assert.EqualError(t, err, "missing header X-Api-Key")
+
+	// empty values are only accepted in lenient mode
+	h = http.Header{"X-Tenant": {" "}}
+	err = RequireHeaders("X-Tenant").Intercept(newReq(h))
+	assert.True(t, errors.Is(err, ErrMissingHeader))
+	assert.NoError(t, RequireHeadersAllowEmpty("X-Tenant").Intercept(newReq(h)))
+	assert.True(t, errors.Is(RequireHeadersAllowEmpty("X-Api-Key").Intercept(newReq(h)), ErrMissingHeader))
+}
+
+func TestRawHeader(t *testing.T) {
+	// a raw HTTP/1.1 server, as net/http canonicalizes the received headers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer func() {
+		_ = l.Close()
+	}()
+
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil || line == "\r\n" {
+				break
+			}
+			got = append(got, strings.TrimRight(line, "\r\n"))
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 204 No Content\r\nConnection: close\r\n\r\n"))
+		lines <- got
+	}()
+
+	c := &http.Client{Transport: NewRequestInterceptor(nil, RawHeader("X-API-Key", "secret"))}
+	req, err := http.NewRequest("GET", "http://"+l.Addr().String()+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Api-Key", "canonical")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	got := <-lines
+	assert.Contains(t, got, "X-API-Key: secret")
+	assert.NotContains(t, got, "X-Api-Key: canonical")
+	// the request of the caller is untouched
+	assert.Equal(t, http.Header{"X-Api-Key": {"canonical"}}, req.Header)
+}
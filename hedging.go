@@ -0,0 +1,134 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// NewHedging returns a roundtripper sending a second attempt of a request when
+// the first one did not complete after delay
+func NewHedging(baseTransport http.RoundTripper, delay time.Duration) *Hedging {
+	return &Hedging{
+		Base:  baseTransport,
+		Delay: delay,
+	}
+}
+
+// Hedging reduces tail latency: when a request takes longer than Delay, a
+// duplicate is sent and the first response to arrive is returned, the other
+// attempt being canceled. Only idempotent requests with a replayable body are
+// hedged. A failed attempt does not end the request while the other one is
+// still running. Close waits for the attempts in flight and the release of the
+// canceled ones, the requests sent afterwards fail with ErrClosed
+type Hedging struct {
+	Base  http.RoundTripper
+	Delay time.Duration
+
+	shutdown shutdown
+}
+
+type hedgeResult struct {
+	attempt int
+	res     *http.Response
+	err     error
+}
+
+// RoundTrip sends the request, and a duplicate if it is too slow
+func (h *Hedging) RoundTrip(req *http.Request) (*http.Response, error) {
+	if h.shutdown.isDone() {
+		closeBody(req)
+		return nil, ErrClosed
+	}
+	if !isIdempotent(req) || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+		return h.base().RoundTrip(req)
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(r *http.Request) bool {
+		ctx, cancel := context.WithCancel(req.Context())
+		attempt := len(cancels)
+		started := h.shutdown.goroutine(func() {
+			res, err := h.base().RoundTrip(r.WithContext(ctx))
+			results <- hedgeResult{attempt: attempt, res: res, err: err}
+		})
+		if !started {
+			cancel()
+			return false
+		}
+		cancels = append(cancels, cancel)
+		return true
+	}
+
+	if !launch(req) {
+		closeBody(req)
+		return nil, ErrClosed
+	}
+	pending := 1
+	hedge := clockFrom(req.Context()).After(h.Delay)
+
+	for {
+		select {
+		case <-hedge:
+			next, err := rewindRequest(req)
+			if err != nil {
+				continue
+			}
+			if !launch(next) {
+				closeBody(next)
+				continue
+			}
+			pending++
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				cancels[r.attempt]()
+				if pending > 0 {
+					// the other attempt may still succeed
+					continue
+				}
+				return nil, r.err
+			}
+			// cancel the slower attempt and release its response
+			for i, cancel := range cancels {
+				if i != r.attempt {
+					cancel()
+				}
+			}
+			for ; pending > 0; pending-- {
+				drain := func() { discardResponse((<-results).res) }
+				if !h.shutdown.goroutine(drain) {
+					drain()
+				}
+			}
+			if r.res.Body == nil || r.res.Body == http.NoBody {
+				cancels[r.attempt]()
+				return r.res, nil
+			}
+			// the winner context lasts until its body is consumed
+			r.res.Body = &onEOFReader{rc: r.res.Body, fn: cancels[r.attempt]}
+			return r.res, nil
+		}
+	}
+}
+
+// Close waits for the attempts in flight, then makes the future requests fail
+// with ErrClosed. It can be called several times
+func (h *Hedging) Close() error {
+	h.shutdown.close()
+	return nil
+}
+
+// Wrap sets base as the Base of Hedging, so it can be used with Stack
+func (h *Hedging) Wrap(base http.RoundTripper) http.RoundTripper {
+	h.Base = base
+	return h
+}
+
+func (h *Hedging) base() http.RoundTripper {
+	if h.Base != nil {
+		return h.Base
+	}
+	return http.DefaultTransport
+}
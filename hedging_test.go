@@ -0,0 +1,116 @@
+package port
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedging(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+			case <-time.After(5 * time.Second):
+			}
+			_, _ = w.Write([]byte("slow"))
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewHedging(c.Transport, 50*time.Millisecond)
+
+	st := time.Now()
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, "fast", string(b))
+	assert.WithinDuration(t, time.Now(), st, time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("the slow attempt has not been canceled")
+	}
+}
+
+func TestHedging_Fast(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewHedging(c.Transport, 200*time.Millisecond)
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHedging_NonIdempotent(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewHedging(c.Transport, 10*time.Millisecond)
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHedging_NoBody(t *testing.T) {
+	for _, body := range []io.ReadCloser{nil, http.NoBody} {
+		var ctx context.Context
+		h := NewHedging(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx = req.Context()
+			return &http.Response{StatusCode: http.StatusNoContent, Body: body, Request: req}, nil
+		}), time.Minute)
+
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		require.NoError(t, err)
+		resp, err := h.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, body, resp.Body)
+		// nothing to read, the attempt is released right away
+		assert.Error(t, ctx.Err())
+		if resp.Body != nil {
+			assert.NoError(t, resp.Body.Close())
+		}
+	}
+}
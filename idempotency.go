@@ -0,0 +1,84 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// DefaultIdempotencyKeyHeader is the header used by IdempotencyKey when none
+// is given
+const DefaultIdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyKeyCtx struct{}
+
+// idempotencyHolder keeps the idempotency key of a logical request across
+// its attempts
+type idempotencyHolder struct {
+	mu  sync.Mutex
+	key string
+}
+
+func (h *idempotencyHolder) get() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.key
+}
+
+// getOrSet returns the key of the holder, setting it with gen if empty
+func (h *idempotencyHolder) getOrSet(gen func() string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.key == "" {
+		h.key = gen()
+	}
+	return h.key
+}
+
+// withIdempotencyHolder returns a context carrying an idempotency key
+// holder, and the holder. ctx is returned as is if it already has one
+func withIdempotencyHolder(ctx context.Context) (context.Context, *idempotencyHolder) {
+	if h, ok := ctx.Value(idempotencyKeyCtx{}).(*idempotencyHolder); ok {
+		return ctx, h
+	}
+	h := &idempotencyHolder{}
+	return context.WithValue(ctx, idempotencyKeyCtx{}, h), h
+}
+
+// IdempotencyKey returns a RequestModifier setting an idempotency key in
+// header (DefaultIdempotencyKeyHeader if empty), generated by gen (UUIDv4 if
+// nil). A key already set by the caller is kept. The key is stored in the
+// request context: attempts of a request retried by RetryTransport all send
+// the same key, and RetryTransport retries keyed requests even when their
+// method is not idempotent
+func IdempotencyKey(header string, gen func() string) RequestModifier {
+	if header == "" {
+		header = DefaultIdempotencyKeyHeader
+	}
+	if gen == nil {
+		gen = newUUID
+	}
+	return RequestModifierFunc(func(req *http.Request) error {
+		ctx, h := withIdempotencyHolder(req.Context())
+		if ctx != req.Context() {
+			setContext(req, ctx)
+		}
+		if existing := req.Header.Get(header); existing != "" {
+			h.getOrSet(func() string { return existing })
+			return nil
+		}
+		req.Header.Set(header, h.getOrSet(gen))
+		return nil
+	})
+}
+
+// IdempotencyKeyFromContext returns the idempotency key used for the request
+// owning ctx
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	h, ok := ctx.Value(idempotencyKeyCtx{}).(*idempotencyHolder)
+	if !ok {
+		return "", false
+	}
+	key := h.get()
+	return key, key != ""
+}
@@ -0,0 +1,106 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func flakyKeyServer(t *testing.T, failures int32) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var keys []string
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(DefaultIdempotencyKeyHeader))
+		mu.Unlock()
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	return s, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestIdempotencyKey_RetryAbove(t *testing.T) {
+	s, keys := flakyKeyServer(t, 2)
+	defer s.Close()
+
+	var generated int32
+	gen := func() string {
+		return "key-" + strconv.Itoa(int(atomic.AddInt32(&generated, 1)))
+	}
+
+	// the modifier runs again on every attempt
+	c := s.Client()
+	c.Transport = NewRetryTransport(NewRequestInterceptor(c.Transport, IdempotencyKey("", gen)), 3, noBackoff)
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, []string{"key-1", "key-1", "key-1"}, keys())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&generated))
+}
+
+func TestIdempotencyKey_RetryBelow(t *testing.T) {
+	s, keys := flakyKeyServer(t, 1)
+	defer s.Close()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(NewRetryTransport(c.Transport, 3, noBackoff), IdempotencyKey("", nil))
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	sent := keys()
+	require.Len(t, sent, 2)
+	assert.Regexp(t, uuidPattern, sent[0])
+	assert.Equal(t, sent[0], sent[1])
+	key, ok := IdempotencyKeyFromContext(resp.Request.Context())
+	require.True(t, ok)
+	assert.Equal(t, sent[0], key)
+}
+
+func TestIdempotencyKey_CallerKey(t *testing.T) {
+	s, keys := flakyKeyServer(t, 1)
+	defer s.Close()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(NewRequestInterceptor(c.Transport, IdempotencyKey("", nil)), 3, noBackoff)
+
+	req, err := http.NewRequest("POST", s.URL, strings.NewReader("payload"))
+	require.NoError(t, err)
+	req.Header.Set(DefaultIdempotencyKeyHeader, "mine")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"mine", "mine"}, keys())
+}
+
+func TestIdempotencyKey_Distinct(t *testing.T) {
+	mod := IdempotencyKey("X-Key", nil)
+	var sent []string
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("POST", "http://example.com", nil)
+		require.NoError(t, err)
+		require.NoError(t, mod.Intercept(req))
+		sent = append(sent, req.Header.Get("X-Key"))
+	}
+	assert.NotEqual(t, sent[0], sent[1])
+}
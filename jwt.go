@@ -0,0 +1,79 @@
+package port
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// JWTClaims are the claims of a JSON Web Token. The exp claim, when set, is a
+// number of seconds since the Unix epoch, a json.Number or a time.Time
+type JWTClaims map[string]any
+
+// JWTSigner builds a signed, compact serialized JSON Web Token out of claims.
+// It keeps JWTAssertion independent of any JWT library
+type JWTSigner interface {
+	Sign(claims JWTClaims) (string, error)
+}
+
+// JWTAssertion returns a RequestModifier authenticating requests with a bearer
+// JSON Web Token, signed by signer over the claims returned for the request.
+// Tokens are cached per host and reused until shortly before their exp claim,
+// claims is only called when a new token is needed. Tokens without exp claim
+// are never reused
+func JWTAssertion(signer JWTSigner, claims func(req *http.Request) JWTClaims) RequestModifier {
+	var mu sync.Mutex
+	tokens := make(map[string]jwtToken) // lowercased host -> token
+	return RequestModifierFunc(func(req *http.Request) error {
+		host := strings.ToLower(req.URL.Host)
+		mu.Lock()
+		defer mu.Unlock()
+		if t, ok := tokens[host]; ok && clockFrom(req.Context()).Now().Add(tokenExpiryDelta).Before(t.expiry) {
+			setBearer(req, t.value)
+			return nil
+		}
+
+		c := claims(req)
+		value, err := signer.Sign(c)
+		if err != nil {
+			return errors.Wrap(err, "unable to sign jwt")
+		}
+		if expiry, ok := jwtExpiry(c); ok {
+			tokens[host] = jwtToken{value: value, expiry: expiry}
+		} else {
+			delete(tokens, host)
+		}
+		setBearer(req, value)
+		return nil
+	})
+}
+
+type jwtToken struct {
+	value  string
+	expiry time.Time
+}
+
+// jwtExpiry returns the time of the exp claim, if any
+func jwtExpiry(c JWTClaims) (time.Time, bool) {
+	switch exp := c["exp"].(type) {
+	case time.Time:
+		return exp, true
+	case int64:
+		return time.Unix(exp, 0), true
+	case int:
+		return time.Unix(int64(exp), 0), true
+	case float64:
+		return time.Unix(int64(exp), 0), true
+	case json.Number:
+		secs, err := exp.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(secs, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,98 @@
+package port
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigner builds unsigned tokens, counting them
+type fakeSigner struct {
+	signed int
+}
+
+func (s *fakeSigner) Sign(claims JWTClaims) (string, error) {
+	s.signed++
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString(payload) + ".sig", nil
+}
+
+func jwtFor(t *testing.T, mod RequestModifier, rawurl string) string {
+	t.Helper()
+	req, err := http.NewRequest("GET", rawurl, nil)
+	require.NoError(t, err)
+	require.NoError(t, mod.Intercept(req))
+	auth := req.Header.Get("Authorization")
+	require.True(t, strings.HasPrefix(auth, "Bearer "), auth)
+	return strings.TrimPrefix(auth, "Bearer ")
+}
+
+func TestJWTAssertion(t *testing.T) {
+	signer := &fakeSigner{}
+	mod := JWTAssertion(signer, func(req *http.Request) JWTClaims {
+		return JWTClaims{
+			"iss": "client",
+			"aud": req.URL.Host,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	})
+
+	token := jwtFor(t, mod, "https://api.example.com/a")
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	assert.Equal(t, "api.example.com", claims["aud"])
+
+	// cached until near exp, per host
+	assert.Equal(t, token, jwtFor(t, mod, "https://api.example.com/b"))
+	assert.Equal(t, 1, signer.signed)
+	assert.NotEqual(t, token, jwtFor(t, mod, "https://other.example.com/"))
+	assert.Equal(t, 2, signer.signed)
+}
+
+func TestJWTAssertion_Expiry(t *testing.T) {
+	signer := &fakeSigner{}
+	// within tokenExpiryDelta, a new token is needed every time
+	mod := JWTAssertion(signer, func(req *http.Request) JWTClaims {
+		return JWTClaims{"exp": time.Now().Add(tokenExpiryDelta / 2)}
+	})
+	jwtFor(t, mod, "https://api.example.com/")
+	jwtFor(t, mod, "https://api.example.com/")
+	assert.Equal(t, 2, signer.signed)
+
+	// no exp claim, no caching
+	signer = &fakeSigner{}
+	mod = JWTAssertion(signer, func(req *http.Request) JWTClaims {
+		return JWTClaims{"sub": "client"}
+	})
+	jwtFor(t, mod, "https://api.example.com/")
+	jwtFor(t, mod, "https://api.example.com/")
+	assert.Equal(t, 2, signer.signed)
+}
+
+type failingSigner struct{}
+
+func (failingSigner) Sign(JWTClaims) (string, error) { return "", errors.New("no key") }
+
+func TestJWTAssertion_Error(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://api.example.com/", nil)
+	require.NoError(t, err)
+	err = JWTAssertion(failingSigner{}, func(*http.Request) JWTClaims { return JWTClaims{} }).Intercept(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no key")
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
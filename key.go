@@ -0,0 +1,68 @@
+package port
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeyFunc tells which requests are the same for Singleflight and
+// CacheTransport: requests with the same key share their response
+type KeyFunc func(req *http.Request) (string, error)
+
+// DefaultKey is the KeyFunc used when none is set: the method and URL of the
+// request
+func DefaultKey(req *http.Request) (string, error) {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + "\n" + req.URL.String(), nil
+}
+
+// HeaderKey returns a KeyFunc extending DefaultKey with the values of the
+// named headers, for responses varying with them. Other headers are ignored
+func HeaderKey(names ...string) KeyFunc {
+	return func(req *http.Request) (string, error) {
+		key, _ := DefaultKey(req)
+		var b strings.Builder
+		b.WriteString(key)
+		for _, name := range names {
+			b.WriteString("\n")
+			b.WriteString(http.CanonicalHeaderKey(name))
+			b.WriteString(": ")
+			b.WriteString(strings.Join(req.Header.Values(name), ", "))
+		}
+		return b.String(), nil
+	}
+}
+
+// BodyKey returns a KeyFunc extending DefaultKey with a hash of the request
+// body, read up to max bytes then restored so it can still be sent. The
+// request is modified: Singleflight and CacheTransport give their KeyFunc a
+// clone of the request of the caller
+func BodyKey(max int64) KeyFunc {
+	return func(req *http.Request) (string, error) {
+		key, _ := DefaultKey(req)
+		body, err := ReadAndRestoreBody(req, max)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to hash request body")
+		}
+		sum := sha256.Sum256(body)
+		return key + "\n" + hex.EncodeToString(sum[:]), nil
+	}
+}
+
+// keyRequest returns the request given to a KeyFunc by the transports, and
+// sent in place of req: a clone when req has a body, so a KeyFunc reading it,
+// as BodyKey does, leaves the Body, GetBody and ContentLength of the caller
+// untouched
+func keyRequest(req *http.Request) *http.Request {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req
+	}
+	return req.Clone(req.Context())
+}
@@ -0,0 +1,131 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requestKey(t *testing.T, fn KeyFunc, method, body string, header http.Header) string {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, "http://example.com/a?b=c", r)
+	require.NoError(t, err)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	key, err := fn(req)
+	require.NoError(t, err)
+	return key
+}
+
+func TestDefaultKey(t *testing.T) {
+	assert.Equal(t, "GET\nhttp://example.com/a?b=c", requestKey(t, DefaultKey, "", "", nil))
+	assert.NotEqual(t, requestKey(t, DefaultKey, "GET", "", nil), requestKey(t, DefaultKey, "HEAD", "", nil))
+}
+
+func TestHeaderKey(t *testing.T) {
+	fn := HeaderKey("accept")
+	// X-Volatile is ignored
+	assert.Equal(t,
+		requestKey(t, fn, "GET", "", http.Header{"Accept": {"application/json"}, "X-Volatile": {"1"}}),
+		requestKey(t, fn, "GET", "", http.Header{"Accept": {"application/json"}, "X-Volatile": {"2"}}),
+	)
+	assert.NotEqual(t,
+		requestKey(t, fn, "GET", "", http.Header{"Accept": {"application/json"}}),
+		requestKey(t, fn, "GET", "", http.Header{"Accept": {"text/html"}}),
+	)
+}
+
+func TestBodyKey(t *testing.T) {
+	fn := BodyKey(DefaultMaxBodySize)
+	assert.Equal(t, requestKey(t, fn, "POST", "a=1", nil), requestKey(t, fn, "POST", "a=1", nil))
+	assert.NotEqual(t, requestKey(t, fn, "POST", "a=1", nil), requestKey(t, fn, "POST", "a=2", nil))
+
+	// the body can still be sent
+	req, err := http.NewRequest("POST", "http://example.com", io.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	_, err = fn(req)
+	require.NoError(t, err)
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(b))
+}
+
+func TestCacheTransport_Key(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, r.Header.Get("Accept"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	ct := NewCacheTransport(c.Transport, NewMemoryCache())
+	ct.Key = HeaderKey("Accept")
+	c.Transport = ct
+
+	get := func(accept, volatile string) string {
+		req, err := http.NewRequest("GET", s.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("X-Volatile", volatile)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "application/json", get("application/json", "1"))
+	assert.Equal(t, "application/json", get("application/json", "2"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "text/html", get("text/html", "1"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestBodyKey_CallerRequestUntouched(t *testing.T) {
+	var bodies []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	base := s.Client().Transport
+	sf := NewSingleflight(base)
+	sf.Key = BodyKey(DefaultMaxBodySize)
+	ct := NewCacheTransport(base, NewMemoryCache())
+	ct.Key = BodyKey(DefaultMaxBodySize)
+
+	for _, rt := range []http.RoundTripper{sf, ct} {
+		body := io.NopCloser(strings.NewReader("query"))
+		req, err := http.NewRequest("GET", s.URL, body)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		discardResponse(resp)
+
+		assert.Equal(t, body, req.Body)
+		assert.Nil(t, req.GetBody)
+		assert.Equal(t, int64(0), req.ContentLength)
+	}
+	assert.Equal(t, []string{"query", "query"}, bodies)
+}
@@ -0,0 +1,63 @@
+package port
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var languageTagPattern = regexp.MustCompile(`^(\*|[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*)$`)
+
+// AcceptLanguage returns a RequestModifier setting the Accept-Language header
+// to tags, in order of preference: the first tag has no quality value, the
+// following ones get decreasing q values (0.9, 0.8... down to 0.1)
+func AcceptLanguage(tags ...string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		return setAcceptLanguage(req, tags)
+	})
+}
+
+// AcceptLanguageFromContext returns a RequestModifier setting the
+// Accept-Language header from the value stored under key in the request
+// context, either a string or a []string of tags formatted as AcceptLanguage
+// does. Requests without such a value are left untouched
+func AcceptLanguageFromContext(key any) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		switch v := req.Context().Value(key).(type) {
+		case string:
+			if v != "" {
+				return setAcceptLanguage(req, []string{v})
+			}
+		case []string:
+			return setAcceptLanguage(req, v)
+		}
+		return nil
+	})
+}
+
+func setAcceptLanguage(req *http.Request, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !languageTagPattern.MatchString(tag) {
+			return errors.Errorf("invalid language tag %q", tag)
+		}
+		q := 10 - i
+		switch {
+		case i == 0:
+			parts[i] = tag
+			continue
+		case q < 1:
+			q = 1
+		}
+		parts[i] = tag + ";q=0." + strconv.Itoa(q)
+	}
+	req.Header.Set("Accept-Language", strings.Join(parts, ", "))
+	return nil
+}
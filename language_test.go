@@ -0,0 +1,53 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptLanguage(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, AcceptLanguage("fr-FR", "fr", "en", "*").Intercept(req))
+	assert.Equal(t, "fr-FR, fr;q=0.9, en;q=0.8, *;q=0.7", req.Header.Get("Accept-Language"))
+}
+
+func TestAcceptLanguage_Many(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	tags := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+	require.NoError(t, AcceptLanguage(tags...).Intercept(req))
+	assert.Equal(t, "a, b;q=0.9, c;q=0.8, d;q=0.7, e;q=0.6, f;q=0.5, g;q=0.4, h;q=0.3, i;q=0.2, j;q=0.1, k;q=0.1, l;q=0.1", req.Header.Get("Accept-Language"))
+}
+
+func TestAcceptLanguage_Invalid(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	require.Error(t, AcceptLanguage("en", "fr;q=2").Intercept(req))
+	assert.Empty(t, req.Header.Get("Accept-Language"))
+}
+
+func TestAcceptLanguageFromContext(t *testing.T) {
+	type langKey struct{}
+	mod := AcceptLanguageFromContext(langKey{})
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	require.NoError(t, mod.Intercept(req))
+	assert.Empty(t, req.Header.Get("Accept-Language"))
+
+	req = req.WithContext(context.WithValue(context.Background(), langKey{}, "de-CH"))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "de-CH", req.Header.Get("Accept-Language"))
+
+	req = req.WithContext(context.WithValue(context.Background(), langKey{}, []string{"de-CH", "de"}))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "de-CH, de;q=0.9", req.Header.Get("Accept-Language"))
+}
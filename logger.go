@@ -0,0 +1,93 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Logger is notified by RequestIntercepter of every request it sends and of
+// its outcome
+type Logger interface {
+	// LogRequest is called with the modified request, right before it is sent
+	LogRequest(req *http.Request)
+	// LogResponse is called once the base transport returned, with either the
+	// response or the transport error, and the time spent waiting for it
+	LogResponse(resp *http.Response, err error, latency time.Duration)
+}
+
+// multiLogger notifies every logger in order
+type multiLogger []Logger
+
+func (m multiLogger) LogRequest(req *http.Request) {
+	for _, l := range m {
+		l.LogRequest(req)
+	}
+}
+
+func (m multiLogger) LogResponse(resp *http.Response, err error, latency time.Duration) {
+	for _, l := range m {
+		l.LogResponse(resp, err, latency)
+	}
+}
+
+// addLogger returns a Logger notifying current, if any, then l
+func addLogger(current, l Logger) Logger {
+	switch {
+	case current == nil:
+		return l
+	case l == nil:
+		return current
+	}
+	if m, ok := current.(multiLogger); ok {
+		return append(m[:len(m):len(m)], l)
+	}
+	return multiLogger{current, l}
+}
+
+type logFieldsKey struct{}
+
+// AddLogFields returns a copy of ctx carrying fields in addition to the log
+// fields it already has. A field already present is kept
+func AddLogFields(ctx context.Context, fields map[string]any) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	existing, _ := ctx.Value(logFieldsKey{}).(map[string]any)
+	merged := make(map[string]any, len(existing)+len(fields))
+	for name, value := range fields {
+		merged[name] = value
+	}
+	for name, value := range existing {
+		merged[name] = value
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// LogFields returns a copy of the log fields stored in ctx by AddLogFields or
+// RequestIntercepter.ExtractLogFields, nil if there are none. Loggers of the
+// application can read them from the context of the response request
+func LogFields(ctx context.Context) map[string]any {
+	fields, _ := ctx.Value(logFieldsKey{}).(map[string]any)
+	if fields == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(fields))
+	for name, value := range fields {
+		copied[name] = value
+	}
+	return copied
+}
+
+// DefaultLogFields returns the method, host and, when set by RequestID, the
+// request id of req as the "method", "host" and "request_id" log fields
+func DefaultLogFields(req *http.Request) map[string]any {
+	fields := map[string]any{
+		"method": req.Method,
+		"host":   req.URL.Host,
+	}
+	if id, ok := RequestIDFromContext(req.Context()); ok {
+		fields["request_id"] = id
+	}
+	return fields
+}
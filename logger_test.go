@@ -0,0 +1,141 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	mu        sync.Mutex
+	requests  []*http.Request
+	responses []*http.Response
+	errs      []error
+	latencies []time.Duration
+}
+
+func (l *recordingLogger) LogRequest(req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, req)
+}
+
+func (l *recordingLogger) LogResponse(resp *http.Response, err error, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.responses = append(l.responses, resp)
+	l.errs = append(l.errs, err)
+	l.latencies = append(l.latencies, latency)
+}
+
+func TestRequestIntercepter_Logger(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	l := &recordingLogger{}
+	c := s.Client()
+	it := NewRequestInterceptor(c.Transport, RequestModifierFunc(func(r *http.Request) error {
+		r.Header.Set("intercepted", "true")
+		return nil
+	}))
+	it.Logger = l
+	c.Transport = it
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Len(t, l.requests, 1)
+	assert.Equal(t, "true", l.requests[0].Header.Get("intercepted"))
+	require.Len(t, l.responses, 1)
+	assert.Equal(t, http.StatusTeapot, l.responses[0].StatusCode)
+	assert.NoError(t, l.errs[0])
+	assert.True(t, l.latencies[0] >= 10*time.Millisecond)
+}
+
+func TestRequestIntercepter_Logger_Error(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := s.URL
+	s.Close()
+
+	l := &recordingLogger{}
+	it := NewRequestInterceptor(nil, nil)
+	it.Logger = l
+
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	_, err = it.RoundTrip(req)
+	require.Error(t, err)
+
+	require.Len(t, l.requests, 1)
+	require.Len(t, l.errs, 1)
+	assert.Equal(t, err, l.errs[0])
+	assert.Nil(t, l.responses[0])
+	assert.True(t, l.latencies[0] > 0)
+}
+
+func TestWithLogFields(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	// the fields are visible to the transports below the interceptor
+	var seen map[string]any
+	base := s.Client().Transport
+	c := &http.Client{Transport: NewInterceptor(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		seen = LogFields(req.Context())
+		return base.RoundTrip(req)
+	}),
+		WithRequestModifier(RequestID("", func() string { return "id-1" })),
+		WithLogFields(nil),
+	)}
+
+	// the fields of the caller are kept
+	ctx := AddLogFields(context.Background(), map[string]any{"user": "bob", "method": "caller"})
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	host := strings.TrimPrefix(s.URL, "http://")
+	want := map[string]any{"user": "bob", "method": "caller", "host": host, "request_id": "id-1"}
+	assert.Equal(t, want, seen)
+	assert.Equal(t, want, LogFields(resp.Request.Context()))
+	assert.Equal(t, map[string]any{"user": "bob", "method": "caller"}, LogFields(ctx))
+
+	// stacked interceptors merge their fields
+	c.Transport = NewInterceptor(c.Transport, WithLogFields(func(req *http.Request) map[string]any {
+		return map[string]any{"path": req.URL.Path, "host": "outer"}
+	}))
+	resp, err = c.Get(s.URL + "/users")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, map[string]any{"path": "/users", "host": "outer", "method": "GET", "request_id": "id-1"}, seen)
+}
+
+func TestLogFields(t *testing.T) {
+	assert.Nil(t, LogFields(context.Background()))
+	assert.Equal(t, context.Background(), AddLogFields(context.Background(), nil))
+
+	ctx := AddLogFields(context.Background(), map[string]any{"a": 1})
+	fields := LogFields(ctx)
+	fields["a"] = 2
+	assert.Equal(t, map[string]any{"a": 1}, LogFields(ctx))
+	assert.Equal(t, map[string]any{"a": 1, "b": 2}, LogFields(AddLogFields(ctx, map[string]any{"a": 3, "b": 2})))
+}
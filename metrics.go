@@ -0,0 +1,14 @@
+package port
+
+import (
+	"time"
+)
+
+// MetricsObserver receives a measure of every round trip made by
+// RequestIntercepter. It has no dependency so it can be backed by Prometheus,
+// OpenTelemetry or anything else
+type MetricsObserver interface {
+	// ObserveRoundTrip is called once the base transport returned. status is
+	// 0 when no response was received, err is the transport error if any
+	ObserveRoundTrip(method, host string, status int, latency time.Duration, err error)
+}
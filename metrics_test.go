@@ -0,0 +1,82 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type observation struct {
+	method  string
+	host    string
+	status  int
+	latency time.Duration
+	err     error
+}
+
+type recordingObserver struct {
+	observations []observation
+}
+
+func (o *recordingObserver) ObserveRoundTrip(method, host string, status int, latency time.Duration, err error) {
+	o.observations = append(o.observations, observation{method, host, status, latency, err})
+}
+
+func TestRequestIntercepter_Metrics(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	o := &recordingObserver{}
+	c := s.Client()
+	it := NewRequestInterceptor(c.Transport, nil)
+	it.Metrics = o
+	c.Transport = it
+
+	resp, err := c.Post(s.URL, "text/plain", nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	require.Len(t, o.observations, 1)
+	obs := o.observations[0]
+	assert.Equal(t, "POST", obs.method)
+	assert.Equal(t, u.Host, obs.host)
+	assert.Equal(t, http.StatusCreated, obs.status)
+	assert.True(t, obs.latency >= 0)
+	assert.NoError(t, obs.err)
+}
+
+func TestRequestIntercepter_Metrics_Error(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+	s.Close()
+
+	o := &recordingObserver{}
+	it := NewRequestInterceptor(nil, nil)
+	it.Metrics = o
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	_, err = it.RoundTrip(req)
+	require.Error(t, err)
+
+	require.Len(t, o.observations, 1)
+	obs := o.observations[0]
+	assert.Equal(t, "GET", obs.method)
+	assert.Equal(t, u.Host, obs.host)
+	assert.Equal(t, 0, obs.status)
+	assert.True(t, obs.latency >= 0)
+	assert.Equal(t, err, obs.err)
+}
@@ -0,0 +1,126 @@
+package port
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMirrorTimeout bounds the mirrored requests when Mirror.Timeout is
+// zero
+const DefaultMirrorTimeout = 30 * time.Second
+
+// DefaultMirrorMaxInFlight bounds the mirrored requests in flight when
+// Mirror.MaxInFlight is zero
+const DefaultMirrorMaxInFlight = 64
+
+// Mirror replays a sample of the requests sent by RequestIntercepter to a
+// shadow backend, e.g. to test a migration against production traffic. The
+// copies are sent asynchronously through the base transport once the
+// modifiers ran, with the scheme and host of Target, and their responses are
+// discarded: the mirror never affects the caller. Requests whose body cannot
+// be replayed are not mirrored, nor the requests sampled while MaxInFlight
+// copies are in flight. Close stops mirroring and waits for the copies
+type Mirror struct {
+	Target *url.URL
+	// SampleRate is the probability of mirroring a request, from 0 to 1
+	SampleRate float64
+	// Timeout bounds every mirrored request, DefaultMirrorTimeout by default.
+	// Canceling the original request does not cancel its copy
+	Timeout time.Duration
+	// MaxInFlight bounds the copies in flight, DefaultMirrorMaxInFlight by
+	// default. The requests sampled past it are not mirrored
+	MaxInFlight int
+
+	inFlight atomic.Int64
+	init     sync.Once
+	ctx      context.Context // canceled by Close
+	cancel   context.CancelFunc
+	shutdown shutdown
+}
+
+// send mirrors req through base, if it is sampled
+func (m *Mirror) send(base http.RoundTripper, req *http.Request) {
+	if m.Target == nil || m.SampleRate <= 0 || (m.SampleRate < 1 && rand.Float64() >= m.SampleRate) {
+		return
+	}
+	if m.inFlight.Add(1) > int64(m.maxInFlight()) {
+		m.inFlight.Add(-1)
+		return
+	}
+	sent := false
+	defer func() {
+		if !sent {
+			m.inFlight.Add(-1)
+		}
+	}()
+	var body io.ReadCloser
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return
+		}
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return
+		}
+	}
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMirrorTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), timeout)
+	stop := context.AfterFunc(m.closing(), cancel)
+	mreq := req.Clone(ctx)
+	mreq.Body = body
+	mreq.URL.Scheme = m.Target.Scheme
+	mreq.URL.Host = m.Target.Host
+	mreq.Host = ""
+	sent = m.shutdown.goroutine(func() {
+		defer m.inFlight.Add(-1)
+		defer stop()
+		defer cancel()
+		res, err := base.RoundTrip(mreq)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	})
+	if !sent {
+		stop()
+		cancel()
+		if body != nil {
+			_ = body.Close()
+		}
+	}
+}
+
+// Close stops mirroring, cancels the copies in flight and waits for them. It
+// can be called several times
+func (m *Mirror) Close() error {
+	m.closing()
+	m.cancel()
+	m.shutdown.close()
+	return nil
+}
+
+// closing returns the context canceled by Close
+func (m *Mirror) closing() context.Context {
+	m.init.Do(func() {
+		m.ctx, m.cancel = context.WithCancel(context.Background())
+	})
+	return m.ctx
+}
+
+func (m *Mirror) maxInFlight() int {
+	if m.MaxInFlight > 0 {
+		return m.MaxInFlight
+	}
+	return DefaultMirrorMaxInFlight
+}
@@ -0,0 +1,149 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, "primary "+string(b))
+	}))
+	type mirrored struct{ method, uri, header, body string }
+	received := make(chan mirrored, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received <- mirrored{r.Method, r.RequestURI, r.Header.Get("X-Injected"), string(b)}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	defer func() {
+		primary.Close()
+		shadow.Close()
+	}()
+
+	target, err := url.Parse(shadow.URL)
+	require.NoError(t, err)
+	c := primary.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithRequestModifier(SetHeaders(http.Header{"X-Injected": {"yes"}})),
+		WithMirror(target, 1),
+	)
+
+	resp, err := c.Post(primary.URL+"/items?q=1", "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "primary payload", string(b))
+
+	select {
+	case m := <-received:
+		assert.Equal(t, mirrored{"POST", "/items?q=1", "yes", "payload"}, m)
+	case <-time.After(time.Second):
+		t.Fatal("request not mirrored")
+	}
+}
+
+func TestWithMirror_Sampling(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	var mirrored int32
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&mirrored, 1)
+	}))
+
+	defer func() {
+		primary.Close()
+		shadow.Close()
+	}()
+
+	target, err := url.Parse(shadow.URL)
+	require.NoError(t, err)
+	c := primary.Client()
+	c.Transport = NewInterceptor(c.Transport, WithMirror(target, 0))
+	for i := 0; i < 20; i++ {
+		resp, err := c.Get(primary.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&mirrored))
+}
+
+func TestWithMirror_Unavailable(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	target, err := url.Parse(shadow.URL)
+	require.NoError(t, err)
+	shadow.Close()
+
+	defer func() {
+		primary.Close()
+	}()
+
+	// the mirror is down, the caller does not notice
+	c := primary.Client()
+	c.Transport = NewInterceptor(c.Transport, WithMirror(target, 1))
+	resp, err := c.Get(primary.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, "ok", string(b))
+}
+
+func TestMirror_MaxInFlightAndClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var mirrored, canceled int32
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "shadow.example.com" {
+			atomic.AddInt32(&mirrored, 1)
+			// hangs until canceled by Close
+			<-req.Context().Done()
+			atomic.AddInt32(&canceled, 1)
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	m := &Mirror{Target: &url.URL{Scheme: "http", Host: "shadow.example.com"}, SampleRate: 1, MaxInFlight: 2}
+	it := NewInterceptor(base)
+	it.Mirror = m
+
+	send := func() {
+		req, err := http.NewRequest("GET", "http://primary.example.com/", nil)
+		require.NoError(t, err)
+		resp, err := it.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&mirrored) == 2 }, time.Second, time.Millisecond)
+	// the copies past the bound are dropped
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&mirrored))
+
+	require.NoError(t, m.Close())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&canceled))
+	require.NoError(t, m.Close())
+
+	// the requests are still sent, without copies
+	send()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&mirrored))
+	assertNoGoroutineLeak(t, before)
+}
@@ -0,0 +1,188 @@
+package port
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// netrcEntry holds the credentials of a machine, or of the default entry
+type netrcEntry struct {
+	login    string
+	password string
+}
+
+// netrcFile is a parsed netrc file
+type netrcFile struct {
+	machines map[string]netrcEntry // lowercased machine name -> credentials
+	fallback *netrcEntry           // the default entry, if any
+}
+
+// NetrcAuth returns a RequestModifier setting the HTTP Basic credentials found
+// in the netrc file at path for the request host. An empty path means $NETRC,
+// or ~/.netrc when unset. A machine entry matches the host with or without its
+// port, the default entry is used when no machine matches. Requests are left
+// untouched when nothing matches or the file does not exist. The file is
+// parsed once, and again whenever it changes
+func NetrcAuth(path string) RequestModifier {
+	c := &netrcCache{path: path}
+	return RequestModifierFunc(func(req *http.Request) error {
+		f, err := c.load()
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			return nil
+		}
+		e, ok := f.lookup(req.URL)
+		if !ok {
+			return nil
+		}
+		return BasicAuth(e.login, e.password).Intercept(req)
+	})
+}
+
+// netrcCache keeps a netrc file parsed until it is modified
+type netrcCache struct {
+	path string
+
+	mu      sync.Mutex // guards the fields below
+	modTime time.Time
+	size    int64
+	file    *netrcFile
+}
+
+// load returns the parsed netrc file, nil if it does not exist
+func (c *netrcCache) load() (*netrcFile, error) {
+	path := c.path
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		c.file = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to stat netrc file")
+	}
+	if c.file != nil && fi.ModTime().Equal(c.modTime) && fi.Size() == c.size {
+		return c.file, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read netrc file")
+	}
+	f, err := parseNetrc(string(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse netrc file %s", path)
+	}
+	c.file, c.modTime, c.size = f, fi.ModTime(), fi.Size()
+	return f, nil
+}
+
+// lookup returns the credentials for the host of u
+func (f *netrcFile) lookup(u *url.URL) (netrcEntry, bool) {
+	if e, ok := f.machines[strings.ToLower(u.Host)]; ok {
+		return e, true
+	}
+	if e, ok := f.machines[strings.ToLower(u.Hostname())]; ok {
+		return e, true
+	}
+	if f.fallback != nil {
+		return *f.fallback, true
+	}
+	return netrcEntry{}, false
+}
+
+// parseNetrc parses the content of a netrc file. Macro definitions are
+// skipped, the first entry of a machine wins
+func parseNetrc(data string) (*netrcFile, error) {
+	f := &netrcFile{machines: make(map[string]netrcEntry)}
+	var current *netrcEntry
+	var name string
+	commit := func() {
+		if current == nil {
+			return
+		}
+		if name == "" {
+			if f.fallback == nil {
+				f.fallback = current
+			}
+		} else if _, ok := f.machines[name]; !ok {
+			f.machines[name] = *current
+		}
+		current = nil
+	}
+
+	inMacro := false
+	var pending string // keyword waiting for its value
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			// a macro ends with an empty line
+			if strings.TrimSpace(line) == "" {
+				inMacro = false
+			}
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			tok := fields[i]
+			if pending != "" {
+				switch pending {
+				case "machine":
+					commit()
+					current, name = &netrcEntry{}, strings.ToLower(tok)
+				case "login":
+					if current != nil {
+						current.login = tok
+					}
+				case "password":
+					if current != nil {
+						current.password = tok
+					}
+				}
+				pending = ""
+				continue
+			}
+			switch tok {
+			case "machine", "login", "password", "account":
+				pending = tok
+			case "default":
+				commit()
+				current, name = &netrcEntry{}, ""
+			case "macdef":
+				// the rest of the line names the macro, its body follows
+				commit()
+				inMacro = true
+				i = len(fields)
+			default:
+				return nil, errors.Errorf("unexpected token %q", tok)
+			}
+		}
+	}
+	if pending != "" {
+		return nil, errors.Errorf("missing value for %s", pending)
+	}
+	commit()
+	return f, nil
+}
@@ -0,0 +1,92 @@
+package port
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNetrc = `# credentials
+machine api.example.com
+	login alice
+	password s3cret
+
+macdef init
+cd /pub
+machine evil.example.com login mallory password nope
+
+machine other.example.com:8443 login bob password hunter2 account ignored
+default login anonymous password guest
+`
+
+func netrcCredentials(t *testing.T, mod RequestModifier, rawurl string) (string, string, bool) {
+	t.Helper()
+	req, err := http.NewRequest("GET", rawurl, nil)
+	require.NoError(t, err)
+	require.NoError(t, mod.Intercept(req))
+	return req.BasicAuth()
+}
+
+func TestNetrcAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(path, []byte(testNetrc), 0o600))
+	mod := NetrcAuth(path)
+
+	// matched without the port
+	user, pass, ok := netrcCredentials(t, mod, "https://api.example.com:443/v1")
+	require.True(t, ok)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, "s3cret", pass)
+
+	user, pass, ok = netrcCredentials(t, mod, "https://other.example.com:8443/")
+	require.True(t, ok)
+	assert.Equal(t, "bob", user)
+	assert.Equal(t, "hunter2", pass)
+
+	// the macro body is not parsed
+	user, _, ok = netrcCredentials(t, mod, "https://evil.example.com/")
+	require.True(t, ok)
+	assert.Equal(t, "anonymous", user)
+}
+
+func TestNetrcAuth_Unmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(path, []byte("machine api.example.com login alice password s3cret\n"), 0o600))
+
+	_, _, ok := netrcCredentials(t, NetrcAuth(path), "https://www.example.com/")
+	assert.False(t, ok)
+}
+
+func TestNetrcAuth_Missing(t *testing.T) {
+	_, _, ok := netrcCredentials(t, NetrcAuth(filepath.Join(t.TempDir(), "missing")), "https://api.example.com/")
+	assert.False(t, ok)
+}
+
+func TestNetrcAuth_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(path, []byte("machine api.example.com login alice password one\n"), 0o600))
+	mod := NetrcAuth(path)
+
+	_, pass, _ := netrcCredentials(t, mod, "https://api.example.com/")
+	assert.Equal(t, "one", pass)
+
+	require.NoError(t, os.WriteFile(path, []byte("machine api.example.com login alice password two\n"), 0o600))
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, future, future))
+	_, pass, _ = netrcCredentials(t, mod, "https://api.example.com/")
+	assert.Equal(t, "two", pass)
+}
+
+func TestNetrcAuth_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(path, []byte("machine api.example.com login\n"), 0o600))
+
+	req, err := http.NewRequest("GET", "https://api.example.com/", nil)
+	require.NoError(t, err)
+	assert.Error(t, NetrcAuth(path).Intercept(req))
+}
@@ -0,0 +1,50 @@
+package port
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tokenExpiryDelta is how long before their expiry tokens are refreshed, so a
+// token does not expire while the request is in flight
+const tokenExpiryDelta = 10 * time.Second
+
+// TokenSource fetches access tokens, e.g. from an OAuth2 token endpoint
+type TokenSource interface {
+	// Token returns a new access token and its expiry, a zero expiry meaning
+	// the token never expires
+	Token(ctx context.Context) (token string, expiry time.Time, err error)
+}
+
+// OAuth2TokenSource returns a RequestModifier authenticating requests with a
+// bearer token from src. The token is cached and reused until shortly before
+// its expiry, concurrent requests waiting for the same refresh
+func OAuth2TokenSource(src TokenSource) RequestModifier {
+	c := &cachedToken{src: src}
+	return BearerTokenFunc(c.token)
+}
+
+type cachedToken struct {
+	src TokenSource
+
+	mu     sync.Mutex // guards the fields below, held during refresh
+	value  string
+	expiry time.Time
+}
+
+func (c *cachedToken) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.value != "" && (c.expiry.IsZero() || clockFrom(ctx).Now().Add(tokenExpiryDelta).Before(c.expiry)) {
+		return c.value, nil
+	}
+	value, expiry, err := c.src.Token(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to refresh token")
+	}
+	c.value, c.expiry = value, expiry
+	return value, nil
+}
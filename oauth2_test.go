@@ -0,0 +1,78 @@
+package port
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingTokenSource struct {
+	calls  int32
+	expiry time.Duration
+	err    error
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	n := atomic.AddInt32(&s.calls, 1)
+	if s.err != nil {
+		return "", time.Time{}, s.err
+	}
+	time.Sleep(10 * time.Millisecond)
+	return "token-" + strconv.Itoa(int(n)), time.Now().Add(s.expiry), nil
+}
+
+func TestOAuth2TokenSource(t *testing.T) {
+	src := &countingTokenSource{expiry: time.Hour}
+	rec := &Recorder{}
+	it := NewRequestInterceptor(rec, OAuth2TokenSource(src))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			if _, err := it.RoundTrip(req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&src.calls))
+	for _, req := range rec.Requests() {
+		assert.Equal(t, "Bearer token-1", req.Header.Get("Authorization"))
+	}
+}
+
+func TestOAuth2TokenSource_Expiry(t *testing.T) {
+	// expiring within the refresh delta
+	src := &countingTokenSource{expiry: time.Second}
+	mod := OAuth2TokenSource(src)
+
+	for i := 1; i <= 3; i++ {
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		require.NoError(t, err)
+		require.NoError(t, mod.Intercept(req))
+		assert.Equal(t, "Bearer token-"+strconv.Itoa(i), req.Header.Get("Authorization"))
+	}
+}
+
+func TestOAuth2TokenSource_Error(t *testing.T) {
+	failure := errors.New("invalid_client")
+	mod := OAuth2TokenSource(&countingTokenSource{err: failure})
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	err = mod.Intercept(req)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, failure)
+}
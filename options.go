@@ -0,0 +1,156 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a RequestIntercepter built by NewInterceptor
+type Option func(k *RequestIntercepter)
+
+// NewInterceptor returns a roundtripper sending requests through baseTransport
+// (http.DefaultTransport if nil), configured by opts
+func NewInterceptor(baseTransport http.RoundTripper, opts ...Option) *RequestIntercepter {
+	k := NewRequestInterceptor(baseTransport, nil)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(k)
+		}
+	}
+	return k
+}
+
+// WithRequestModifier sets the modifier invoked with every request. Given
+// several times, the modifiers are chained in order
+func WithRequestModifier(mod RequestModifier) Option {
+	return func(k *RequestIntercepter) {
+		if k.requestModifier == nil {
+			k.requestModifier = mod
+			return
+		}
+		k.requestModifier = ChainModifiers(k.requestModifier, mod)
+	}
+}
+
+// WithResponseModifier sets RequestIntercepter.ResponseModifier. Given
+// several times, the modifiers are chained in order
+func WithResponseModifier(mod ResponseModifier) Option {
+	return func(k *RequestIntercepter) {
+		if k.ResponseModifier == nil {
+			k.ResponseModifier = mod
+			return
+		}
+		k.ResponseModifier = ChainResponseModifiers(k.ResponseModifier, mod)
+	}
+}
+
+// WithLogger sets RequestIntercepter.Logger. Given several times, every
+// logger is notified in order
+func WithLogger(l Logger) Option {
+	return func(k *RequestIntercepter) {
+		k.Logger = addLogger(k.Logger, l)
+	}
+}
+
+// WithClock makes the interceptor, its modifiers and the transports below it
+// tell the time with c instead of the time package. It is meant for tests
+// advancing time deterministically, with a fake implementing Now and After
+func WithClock(c Clock) Option {
+	return func(k *RequestIntercepter) {
+		k.clock = c
+	}
+}
+
+// WithMaxResponseBody sets RequestIntercepter.MaxResponseBodySize. Limit the
+// response headers with the MaxResponseHeaderBytes of the base transport
+func WithMaxResponseBody(n int64) Option {
+	return func(k *RequestIntercepter) {
+		k.MaxResponseBodySize = n
+	}
+}
+
+// WithAutoDrain sets RequestIntercepter.AutoDrainSize, discarding up to max
+// unread bytes of the response bodies when they are closed
+func WithAutoDrain(max int64) Option {
+	return func(k *RequestIntercepter) {
+		k.AutoDrainSize = max
+	}
+}
+
+// WithLogFields sets RequestIntercepter.ExtractLogFields to extract, or to
+// DefaultLogFields if nil
+func WithLogFields(extract func(*http.Request) map[string]any) Option {
+	if extract == nil {
+		extract = DefaultLogFields
+	}
+	return func(k *RequestIntercepter) {
+		k.ExtractLogFields = extract
+	}
+}
+
+// WithMirror sets RequestIntercepter.Mirror, replaying the given proportion of
+// the requests to target. Close the Mirror of the interceptor to wait for the
+// copies in flight
+func WithMirror(target *url.URL, sampleRate float64) Option {
+	return func(k *RequestIntercepter) {
+		k.Mirror = &Mirror{Target: target, SampleRate: sampleRate}
+	}
+}
+
+// WithClientTrace sets RequestIntercepter.OnPhases, reporting the phase
+// durations of every round trip to onDone
+func WithClientTrace(onDone func(Phases)) Option {
+	return func(k *RequestIntercepter) {
+		k.OnPhases = onDone
+	}
+}
+
+// WithDump adds a Dumper writing every request and response to w, along with
+// their bodies when includeBody is set. DefaultRedactedHeaders are redacted
+func WithDump(w io.Writer, includeBody bool) Option {
+	return WithLogger(&Dumper{W: w, IncludeBody: includeBody})
+}
+
+// WithTimeout sets RequestIntercepter.Timeout
+func WithTimeout(d time.Duration) Option {
+	return func(k *RequestIntercepter) {
+		k.Timeout = d
+	}
+}
+
+// WithMaxBodySize sets RequestIntercepter.MaxBodySize
+func WithMaxBodySize(n int64) Option {
+	return func(k *RequestIntercepter) {
+		k.MaxBodySize = n
+	}
+}
+
+// WithoutRecover sets RequestIntercepter.DisableRecover
+func WithoutRecover() Option {
+	return func(k *RequestIntercepter) {
+		k.DisableRecover = true
+	}
+}
+
+// WithErrorMapper sets RequestIntercepter.ErrorMapper
+func WithErrorMapper(fn func(req *http.Request, err error) error) Option {
+	return func(k *RequestIntercepter) {
+		k.ErrorMapper = fn
+	}
+}
+
+// WithMetrics sets RequestIntercepter.Metrics
+func WithMetrics(m MetricsObserver) Option {
+	return func(k *RequestIntercepter) {
+		k.Metrics = m
+	}
+}
+
+// WithResponseBytesCallback sets RequestIntercepter.ResponseBytesCallback
+func WithResponseBytesCallback(fn func(bytesRead int64)) Option {
+	return func(k *RequestIntercepter) {
+		k.ResponseBytesCallback = fn
+	}
+}
@@ -0,0 +1,278 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewInterceptor(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("seen", strings.Join(r.Header.Values("X-Step"), ","))
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	l := &recordingLogger{}
+	o := &recordingObserver{}
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithRequestModifier(appendHeader("a")),
+		WithRequestModifier(appendHeader("b")),
+		WithResponseModifier(ResponseModifierFunc(func(resp *http.Response) error {
+			resp.Header.Set("modified", "true")
+			return nil
+		})),
+		WithResponseModifier(appendResponseHeader("r")),
+		WithLogger(l),
+		WithMetrics(o),
+		nil,
+	)
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "a,b", resp.Header.Get("seen"))
+	assert.Equal(t, "true", resp.Header.Get("modified"))
+	assert.Equal(t, "r", resp.Header.Get("X-Step"))
+	assert.Len(t, l.requests, 1)
+	assert.Len(t, l.responses, 1)
+	require.Len(t, o.observations, 1)
+	assert.Equal(t, http.StatusCreated, o.observations[0].status)
+}
+
+func TestNewInterceptor_Defaults(t *testing.T) {
+	it := NewInterceptor(nil)
+	assert.Equal(t, http.DefaultTransport, it.Base)
+	assert.Nil(t, it.requestModifier)
+	assert.Zero(t, it.Timeout)
+}
+
+func TestWithTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithTimeout(100*time.Millisecond))
+
+	_, err := c.Get(s.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport,
+		WithMaxBodySize(4),
+		WithRequestModifier(RequestModifierFunc(func(r *http.Request) error {
+			_, err := io.ReadAll(r.Body)
+			return err
+		})),
+	)
+
+	// no GetBody, the body has to be buffered by the interceptor
+	_, err := c.Post(s.URL, "text/plain", io.NopCloser(strings.NewReader("larger than four")))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBodyNotReplayable))
+}
+
+func TestWithErrorMapper(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	s.Close()
+
+	sentinel := errors.New("mapped")
+	it := NewInterceptor(nil, WithErrorMapper(func(req *http.Request, err error) error {
+		return sentinel
+	}))
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	_, err = it.RoundTrip(req)
+	assert.Equal(t, sentinel, err)
+}
+
+func TestWithoutRecover(t *testing.T) {
+	it := NewInterceptor(nil,
+		WithoutRecover(),
+		WithRequestModifier(RequestModifierFunc(func(r *http.Request) error {
+			panic("boom")
+		})),
+	)
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = it.RoundTrip(req)
+	})
+}
+
+func TestWithResponseBytesCallback(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var counts []int64
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithResponseBytesCallback(func(n int64) {
+		counts = append(counts, n)
+	}))
+
+	// whole body, then close: reported once
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []int64{1000}, counts)
+
+	// partial body
+	counts = nil
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_, err = io.ReadFull(resp.Body, make([]byte, 10))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []int64{10}, counts)
+
+	// no body
+	counts = nil
+	resp, err = c.Head(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, []int64{0}, counts)
+}
+
+func TestWithMaxResponseBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var counts []int64
+	c := s.Client()
+	base := c.Transport
+	c.Transport = NewInterceptor(base, WithMaxResponseBody(100), WithResponseBytesCallback(func(n int64) {
+		counts = append(counts, n)
+	}))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	assert.Equal(t, ErrResponseTooLarge, err)
+	assert.Len(t, b, 100)
+	// the request is complete once the limit is reached
+	assert.Equal(t, []int64{100}, counts)
+	_ = resp.Body.Close()
+	assert.Equal(t, []int64{100}, counts)
+
+	// a body within the limit is read as usual
+	c.Transport = NewInterceptor(base, WithMaxResponseBody(1000))
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	b, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Len(t, b, 1000)
+}
+
+func TestWithAutoDrain(t *testing.T) {
+	body := strings.NewReader(strings.Repeat("a", 100))
+	var closed bool
+	var counts []int64
+	k := NewInterceptor(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       multiReadCloser{Reader: body, Closer: closerFunc(func() error { closed = true; return nil })},
+			Request:    req,
+		}, nil
+	}), WithAutoDrain(50), WithResponseBytesCallback(func(n int64) {
+		counts = append(counts, n)
+	}))
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	require.NoError(t, err)
+	resp, err := k.RoundTrip(req)
+	require.NoError(t, err)
+	_, err = io.ReadFull(resp.Body, make([]byte, 10))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.True(t, closed)
+	// up to 50 unread bytes are discarded, the callback only counts the bytes
+	// read by the caller
+	assert.Equal(t, 40, body.Len())
+	assert.Equal(t, []int64{10}, counts)
+}
+
+func TestWithAutoDrain_ConnectionReuse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// larger than what http.Transport drains by itself
+		_, _ = w.Write(bytes.Repeat([]byte("a"), 1<<20))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	dials := func(opts ...Option) int32 {
+		var n int32
+		tr := s.Client().Transport.(*http.Transport).Clone()
+		dial := (&net.Dialer{}).DialContext
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&n, 1)
+			return dial(ctx, network, addr)
+		}
+		defer tr.CloseIdleConnections()
+
+		c := &http.Client{Transport: NewInterceptor(tr, opts...)}
+		for i := 0; i < 3; i++ {
+			resp, err := c.Get(s.URL)
+			require.NoError(t, err)
+			_, err = io.ReadFull(resp.Body, make([]byte, 10))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+		return atomic.LoadInt32(&n)
+	}
+
+	// the partially read bodies are closed with their connection
+	assert.Equal(t, int32(3), dials())
+	assert.Equal(t, int32(1), dials(WithAutoDrain(2<<20)))
+	// too much left to drain
+	assert.Equal(t, int32(3), dials(WithAutoDrain(1<<10)))
+}
@@ -1,10 +1,15 @@
 package port
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
-	"net/url"
+	"net/http/httptrace"
+	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -22,8 +27,119 @@ type RequestModifier interface {
 	Intercept(req *http.Request) error
 }
 
+// AdvancedModifier is a RequestModifier able to replace the request rather
+// than modify it, e.g. to rebuild it with a new body or URL. RequestIntercepter
+// sends the request returned by ModifyRequest, the body of the replaced
+// request is closed. The replacement must keep the context of the request it
+// replaces, or derive its own from it, for timeouts and cancellation to apply
+type AdvancedModifier interface {
+	RequestModifier
+	ModifyRequest(req *http.Request) (*http.Request, error)
+}
+
+// AdvancedModifierFunc is used to transform a simple function as an
+// AdvancedModifier. A nil request returned by the function keeps the current
+// one
+type AdvancedModifierFunc func(req *http.Request) (*http.Request, error)
+
+// ModifyRequest returns the request to send in place of req
+func (r AdvancedModifierFunc) ModifyRequest(req *http.Request) (*http.Request, error) {
+	return r(req)
+}
+
+// Intercept overwrites req with its replacement, so the modifier can be used
+// wherever a RequestModifier is expected, e.g. in ChainModifiers
+func (r AdvancedModifierFunc) Intercept(req *http.Request) error {
+	r2, err := applyModifier(r, req)
+	if err != nil {
+		return err
+	}
+	if r2 != req {
+		*req = *r2
+	}
+	return nil
+}
+
+// ContextualModifier is a RequestModifier also given the request of the
+// caller, as it was before any modifier ran, e.g. to keep the original value
+// of a header it transforms. RequestIntercepter, and the chains it runs, call
+// InterceptWith instead of Intercept. orig must not be modified
+type ContextualModifier interface {
+	RequestModifier
+	InterceptWith(clone, orig *http.Request) error
+}
+
+// ContextualModifierFunc is used to transform a simple function as a
+// ContextualModifier
+type ContextualModifierFunc func(clone, orig *http.Request) error
+
+// InterceptWith modifies the clone with the ContextualModifierFunc function
+func (r ContextualModifierFunc) InterceptWith(clone, orig *http.Request) error {
+	return r(clone, orig)
+}
+
+// Intercept calls the function with the original request sent through
+// RequestIntercepter, or with req itself outside of it
+func (r ContextualModifierFunc) Intercept(req *http.Request) error {
+	return r(req, originalRequest(req))
+}
+
+type originalRequestKey struct{}
+
+// originalRequest returns the request of the caller of RequestIntercepter
+// req was cloned from, req if none
+func originalRequest(req *http.Request) *http.Request {
+	if orig, ok := req.Context().Value(originalRequestKey{}).(*http.Request); ok {
+		return orig
+	}
+	return req
+}
+
+// runModifier applies mod to req in place, through InterceptWith for a
+// ContextualModifier
+func runModifier(mod RequestModifier, req *http.Request) error {
+	if cm, ok := mod.(ContextualModifier); ok {
+		return cm.InterceptWith(req, originalRequest(req))
+	}
+	return mod.Intercept(req)
+}
+
+// applyModifier runs mod on req and returns the request to send
+func applyModifier(mod RequestModifier, req *http.Request) (*http.Request, error) {
+	am, ok := mod.(AdvancedModifier)
+	if !ok {
+		return req, runModifier(mod, req)
+	}
+	r2, err := am.ModifyRequest(req)
+	if err != nil {
+		return req, err
+	}
+	if r2 == nil || r2 == req {
+		return req, nil
+	}
+	if r2.Body != req.Body {
+		closeBody(req)
+	}
+	return r2, nil
+}
+
+// ResponseModifierFunc is used to transform a simple function as a ResponseModifier
+type ResponseModifierFunc func(resp *http.Response) error
+
+// ModifyResponse modifies the response with the ResponseModifierFunc function
+func (r ResponseModifierFunc) ModifyResponse(resp *http.Response) error {
+	return r(resp)
+}
+
+// ResponseModifier is invoked by RequestInterceptor to modify the response
+// returned by the base transport
+type ResponseModifier interface {
+	ModifyResponse(resp *http.Response) error
+}
+
 // NewRequestInterceptor returns a roundtripper that adds the service key
-// on every request
+// on every request. It is kept for compatibility, NewInterceptor takes
+// options for the other features
 func NewRequestInterceptor(baseTransport http.RoundTripper, modifier RequestModifier) *RequestIntercepter {
 	t := baseTransport
 	if t == nil {
@@ -41,8 +157,58 @@ func NewRequestInterceptor(baseTransport http.RoundTripper, modifier RequestModi
 type RequestIntercepter struct {
 	requestModifier RequestModifier
 	Base            http.RoundTripper
-	mu              sync.Mutex                      // guards modReq
-	modReq          map[*http.Request]*http.Request // original -> modified
+	// ResponseModifier, if set, is invoked with every response successfully
+	// returned by Base, before it is handed back to the caller
+	ResponseModifier ResponseModifier
+	// Timeout, if non-zero, bounds the duration of every request, from its
+	// dispatch until its response body is read or closed. A tighter deadline
+	// set by the caller on the request context still applies
+	Timeout time.Duration
+	// MaxBodySize bounds the request body buffered in memory when the request
+	// has no GetBody, DefaultMaxBodySize by default. Larger bodies are
+	// streamed as is: modifiers reading them make the request fail with
+	// ErrBodyNotReplayable
+	MaxBodySize int64
+	// DisableRecover lets panics of modifiers propagate. By default they are
+	// recovered and returned as a *PanicError
+	DisableRecover bool
+	// Logger, if set, is notified of every request sent and of its outcome
+	Logger Logger
+	// ErrorMapper, if set, is called with the request sent and the error
+	// returned by Base, and returns the error handed to the caller instead,
+	// e.g. a typed error easier to classify. An error cannot be suppressed:
+	// when ErrorMapper returns nil the original error is returned
+	ErrorMapper func(req *http.Request, err error) error
+	// Metrics, if set, observes the outcome and latency of every round trip
+	Metrics MetricsObserver
+	// ResponseBytesCallback, if set, is called once per response with the
+	// number of body bytes read by the caller, when the body reaches EOF or
+	// is closed
+	ResponseBytesCallback func(bytesRead int64)
+	// MaxResponseBodySize, if positive, caps the response body read by the
+	// caller: reading past it fails with ErrResponseTooLarge. The size of the
+	// response headers is capped by the base transport, see
+	// http.Transport.MaxResponseHeaderBytes
+	MaxResponseBodySize int64
+	// AutoDrainSize, if positive, is the number of unread response body bytes
+	// discarded when the caller closes the body, so the connection can be
+	// reused. Bodies with more unread bytes are closed after as many. On its
+	// own http.Transport only drains small bodies of known length
+	AutoDrainSize int64
+	// ExtractLogFields, if set, computes log fields from the modified request,
+	// which are added to its context, see LogFields
+	ExtractLogFields func(req *http.Request) map[string]any
+	// OnPhases, if set, is called once the base transport returned with the
+	// durations of the phases of the round trip. The trace measuring them is
+	// added to the one of the request context, if any
+	OnPhases func(Phases)
+	// Mirror, if set, replays a sample of the modified requests to a shadow
+	// backend
+	Mirror *Mirror
+
+	clock  Clock                           // set by WithClock
+	mu     sync.Mutex                      // guards modReq
+	modReq map[*http.Request]*http.Request // original -> modified
 }
 
 // RoundTrip process the current request before sending it to the real HTTP layer
@@ -57,50 +223,262 @@ func (k *RequestIntercepter) RoundTrip(req *http.Request) (res *http.Response, e
 	}
 
 	req2 := cloneRequest(req) // per RoundTripper contract
+	// contextual modifiers compare the clone with the request of the caller
+	setContext(req2, context.WithValue(req2.Context(), originalRequestKey{}, req))
+	err = k.detachBody(req, req2)
+	if err != nil {
+		return nil, err
+	}
+	if k.clock != nil {
+		setContext(req2, withClock(req2.Context(), k.clock))
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if k.Timeout > 0 {
+		// a tighter deadline of the caller is kept by WithTimeout
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req2.Context(), k.Timeout)
+		setContext(req2, ctx)
+	}
+	// the timeout must last until the body is consumed
+	bodyWrapped := false
+	defer func() {
+		if !bodyWrapped {
+			cancel()
+		}
+	}()
 
-	// modify the copied request
-	err = k.requestModifier.Intercept(req2)
+	// modify the copied request, or replace it
+	req2, err = k.intercept(req2)
 	if err != nil {
+		closeBody(req2)
 		return nil, errors.Wrap(err, "error while intercepting request")
 	}
+	err = rewindBody(req2)
+	if err != nil {
+		return nil, err
+	}
+	if k.Mirror != nil {
+		k.Mirror.send(k.base(), req2)
+	}
 
+	if k.ExtractLogFields != nil {
+		setContext(req2, AddLogFields(req2.Context(), k.ExtractLogFields(req2)))
+	}
 	k.setModReq(req, req2)
+	if k.Logger != nil {
+		k.Logger.LogRequest(req2)
+	}
+	var phases *phasesRecorder
+	if k.OnPhases != nil {
+		phases = newPhasesRecorder(clockFrom(req2.Context()))
+		setContext(req2, httptrace.WithClientTrace(req2.Context(), phases.trace()))
+	}
+	clk := clockFrom(req2.Context())
+	start := clk.Now()
 	res, err = k.base().RoundTrip(req2)
+	latency := clk.Now().Sub(start)
+	if phases != nil {
+		k.OnPhases(phases.done())
+	}
+	if k.Logger != nil {
+		k.Logger.LogResponse(res, err, latency)
+	}
+	if k.Metrics != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		k.Metrics.ObserveRoundTrip(req2.Method, req2.URL.Host, status, latency, err)
+	}
 
 	// req.Body is assumed to have been closed by the base RoundTripper.
 	reqBodyClosed = true
 
 	if err != nil {
 		k.setModReq(req, nil)
-		return nil, err
+		return nil, k.mapError(req2, err)
+	}
+
+	if k.ResponseModifier != nil {
+		err = k.ResponseModifier.ModifyResponse(res)
+		if err != nil {
+			_ = res.Body.Close()
+			k.setModReq(req, nil)
+			return nil, errors.Wrap(err, "error while modifying response")
+		}
+	}
+
+	if res.Body == nil || res.Body == http.NoBody {
+		// nothing will ever be read from the body (HEAD, 204, 304...), the
+		// request is already complete
+		k.setModReq(req, nil)
+		if k.ResponseBytesCallback != nil {
+			k.ResponseBytesCallback(0)
+		}
+		return res, nil
+	}
+
+	// callers giving up on the request without closing the body must not leak
+	// the modReq entry
+	stop := context.AfterFunc(req2.Context(), func() { k.setModReq(req, nil) })
+	bodyWrapped = true
+	rc := res.Body
+	if k.MaxResponseBodySize > 0 {
+		rc = &limitedBody{ReadCloser: rc, remaining: k.MaxResponseBodySize, tooLarge: ErrResponseTooLarge}
 	}
-	res.Body = &onEOFReader{
-		rc: res.Body,
-		fn: func() { k.setModReq(req, nil) },
+	body := &onEOFReader{rc: rc, drain: k.AutoDrainSize}
+	body.fn = func() {
+		stop()
+		cancel()
+		k.setModReq(req, nil)
+		if k.ResponseBytesCallback != nil {
+			k.ResponseBytesCallback(body.read)
+		}
 	}
+	res.Body = body
 	return res, nil
 }
 
+// PanicError is returned by RequestIntercepter when a modifier panicked
+type PanicError struct {
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("request modifier panicked: %v\n%s", e.Value, e.Stack)
+}
+
+// mapError translates a transport error with ErrorMapper
+func (k *RequestIntercepter) mapError(req *http.Request, err error) error {
+	if k.ErrorMapper == nil {
+		return err
+	}
+	if mapped := k.ErrorMapper(req, err); mapped != nil {
+		return mapped
+	}
+	return err
+}
+
+// intercept applies the configured modifier then the ones carried by the
+// request context. A panicking modifier is turned into a *PanicError unless
+// DisableRecover is set
+func (k *RequestIntercepter) intercept(req *http.Request) (out *http.Request, err error) {
+	// out is always the latest request, so its body can be closed on failure
+	out = req
+	if !k.DisableRecover {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+	}
+	if k.requestModifier != nil {
+		if out, err = applyModifier(k.requestModifier, out); err != nil {
+			return out, err
+		}
+	}
+	for _, m := range contextModifiers(out.Context()) {
+		if out, err = applyModifier(m, out); err != nil {
+			return out, errors.Wrap(err, "context modifier failed")
+		}
+	}
+	return out, nil
+}
+
+// cloneRequest returns a deep copy of r sharing its context and body, per the
+// RoundTripper contract: modifiers can change its header, URL, trailer and
+// forms without touching the ones of the caller. It relies on req.Clone so
+// fields added to http.Request later on are copied as well
 func cloneRequest(r *http.Request) *http.Request {
-	// shallow copy of the struct
-	r2 := new(http.Request)
-	*r2 = *r
-	// deep copy of the Header
-	r2.Header = make(http.Header, len(r.Header))
-	for k, s := range r.Header {
-		r2.Header[k] = append([]string(nil), s...)
-	}
-	// Deep copy the URL because it isn't
-	// a map and the URL is mutable by users
-	// of Intercept.
-	if r.URL != nil {
-		r2URL := new(url.URL)
-		*r2URL = *r.URL
-		r2.URL = r2URL
+	r2 := r.Clone(r.Context())
+	if r2.Header == nil {
+		// modifiers expect a header to set values in
+		r2.Header = make(http.Header)
 	}
 	return r2
 }
 
+// detachBody gives req2 a body of its own, obtained from req.GetBody, so a
+// modifier reading it does not consume the body sent upstream. Requests
+// without GetBody are buffered in memory first, up to MaxBodySize
+func (k *RequestIntercepter) detachBody(req, req2 *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	if req.GetBody == nil {
+		max := k.MaxBodySize
+		if max <= 0 {
+			max = DefaultMaxBodySize
+		}
+		b, err := io.ReadAll(io.LimitReader(req.Body, max+1))
+		if err != nil {
+			return errors.Wrap(err, "unable to buffer request body")
+		}
+		if int64(len(b)) > max {
+			// too large to be buffered, stream it without replay support
+			req2.Body = &bodyTracker{ReadCloser: &multiReadCloser{
+				Reader: io.MultiReader(bytes.NewReader(b), req.Body),
+				Closer: req.Body,
+			}}
+			return nil
+		}
+		_ = req.Body.Close()
+		setBody(req2, b)
+	}
+
+	body, err := req2.GetBody()
+	if err != nil {
+		return errors.Wrap(err, "unable to get request body")
+	}
+	_ = req.Body.Close()
+	req2.Body = &bodyTracker{ReadCloser: body}
+	return nil
+}
+
+// rewindBody gives req a fresh body, from req.GetBody, when a modifier read
+// the one set by detachBody without replacing it
+func rewindBody(req *http.Request) error {
+	t, ok := req.Body.(*bodyTracker)
+	if !ok {
+		return nil
+	}
+	if !t.read {
+		req.Body = t.ReadCloser
+		return nil
+	}
+	_ = t.Close()
+	if req.GetBody == nil {
+		return errors.Wrap(ErrBodyNotReplayable, "request body consumed by a modifier")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return errors.Wrap(err, "unable to get request body")
+	}
+	req.Body = body
+	return nil
+}
+
+// bodyTracker records whether its body has been read
+type bodyTracker struct {
+	io.ReadCloser
+	read bool
+}
+
+func (t *bodyTracker) Read(p []byte) (int, error) {
+	t.read = true
+	return t.ReadCloser.Read(p)
+}
+
+// setContext replaces the context of req in place, so modifiers can
+// carry values to the base transport and to the response
+func setContext(req *http.Request, ctx context.Context) {
+	*req = *req.WithContext(ctx)
+}
+
 // CancelRequest cancels an in-flight request by closing its connection.
 // @deprecated use context instead
 func (k *RequestIntercepter) CancelRequest(req *http.Request) {
@@ -116,6 +494,18 @@ func (k *RequestIntercepter) CancelRequest(req *http.Request) {
 	}
 }
 
+// ModifiedRequest returns the request actually sent to Base for the given
+// original request. The entry only exists while the request is in flight:
+// from its dispatch until its response body is read to EOF or closed, or its
+// context is done. Responses without a body drop the entry before RoundTrip
+// returns
+func (k *RequestIntercepter) ModifiedRequest(orig *http.Request) (*http.Request, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	mod, ok := k.modReq[orig]
+	return mod, ok
+}
+
 func (k *RequestIntercepter) setModReq(orig, mod *http.Request) {
 	k.mu.Lock()
 	defer k.mu.Unlock()
@@ -129,6 +519,12 @@ func (k *RequestIntercepter) setModReq(orig, mod *http.Request) {
 	}
 }
 
+// Wrap sets base as the Base of RequestIntercepter, so it can be used with Stack
+func (k *RequestIntercepter) Wrap(base http.RoundTripper) http.RoundTripper {
+	k.Base = base
+	return k
+}
+
 func (k *RequestIntercepter) base() http.RoundTripper {
 	if k.Base != nil {
 		return k.Base
@@ -137,19 +533,26 @@ func (k *RequestIntercepter) base() http.RoundTripper {
 }
 
 type onEOFReader struct {
-	rc io.ReadCloser
-	fn func()
+	rc    io.ReadCloser
+	fn    func()
+	read  int64 // bytes read so far
+	drain int64 // unread bytes discarded on Close
 }
 
 func (r *onEOFReader) Read(p []byte) (n int, err error) {
 	n, err = r.rc.Read(p)
-	if err == io.EOF {
+	r.read += int64(n)
+	if err == io.EOF || err == ErrResponseTooLarge {
+		// nothing more will be read from the body
 		r.runFunc()
 	}
 	return
 }
 
 func (r *onEOFReader) Close() error {
+	if r.drain > 0 && r.fn != nil {
+		_, _ = io.CopyN(io.Discard, r.rc, r.drain)
+	}
 	err := r.rc.Close()
 	r.runFunc()
 	return err
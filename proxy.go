@@ -0,0 +1,25 @@
+package port
+
+import (
+	"net/http/httputil"
+	"net/url"
+)
+
+// ReverseProxy returns a reverse proxy sending the requests it serves to
+// target through k, so the modifiers used by clients apply to proxied
+// requests as well. Incoming paths are appended to the path of target, and
+// the Host header is the one of target. The response modifier of k rewrites
+// the responses served. X-Forwarded headers are not set, add ForwardedFor to
+// the modifiers of k for that. A nil k sends the requests through
+// http.DefaultTransport
+func ReverseProxy(target *url.URL, k *RequestIntercepter) *httputil.ReverseProxy {
+	if k == nil {
+		k = NewInterceptor(nil)
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+		},
+		Transport: k,
+	}
+}
@@ -0,0 +1,66 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Seen", r.Header.Get("X-Injected")+" "+r.Header.Get("X-Forwarded-For"))
+		_, _ = io.WriteString(w, r.Host+" "+r.URL.Path+" "+string(b))
+	}))
+
+	defer func() {
+		backend.Close()
+	}()
+
+	target, err := url.Parse(backend.URL + "/base")
+	require.NoError(t, err)
+	k := NewInterceptor(nil,
+		WithRequestModifier(ChainModifiers(SetHeaders(http.Header{"X-Injected": {"yes"}}), ForwardedFor(nil))),
+		WithResponseModifier(appendResponseHeader("proxied")),
+	)
+	proxy := httptest.NewServer(ReverseProxy(target, k))
+
+	defer func() {
+		proxy.Close()
+	}()
+
+	resp, err := http.Post(proxy.URL+"/items", "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, strings.TrimPrefix(backend.URL, "http://")+" /base/items payload", string(b))
+	assert.Equal(t, "yes 127.0.0.1", resp.Header.Get("X-Seen"))
+	assert.Equal(t, []string{"proxied"}, resp.Header.Values("X-Step"))
+}
+
+func TestReverseProxy_Error(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	target, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	backend.Close()
+
+	proxy := httptest.NewServer(ReverseProxy(target, nil))
+
+	defer func() {
+		proxy.Close()
+	}()
+
+	resp, err := http.Get(proxy.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
@@ -0,0 +1,54 @@
+package port
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQuotaExceeded is returned by the Quota modifier when a request would go
+// over the budget of its key
+var ErrQuotaExceeded = errors.New("request quota exceeded")
+
+// Quota returns a RequestModifier allowing at most limit requests per key
+// over any sliding window of the given duration, e.g. 10000 a day per host.
+// Requests over budget fail with ErrQuotaExceeded before being sent and are
+// not counted. A limit of zero or less rejects every request. keyFn defaults
+// to KeyByHost
+func Quota(limit int, window time.Duration, keyFn func(req *http.Request) string) RequestModifier {
+	if keyFn == nil {
+		keyFn = KeyByHost
+	}
+	if limit <= 0 {
+		return RequestModifierFunc(func(req *http.Request) error {
+			return errors.Wrapf(ErrQuotaExceeded, "%s: no request allowed", keyFn(req))
+		})
+	}
+	var mu sync.Mutex
+	sent := make(map[string][]time.Time) // key -> dispatch times, oldest first
+	return RequestModifierFunc(func(req *http.Request) error {
+		key := keyFn(req)
+		now := clockFrom(req.Context()).Now()
+
+		mu.Lock()
+		defer mu.Unlock()
+		times := sent[key]
+		expired := 0
+		for expired < len(times) && !times[expired].After(now.Add(-window)) {
+			expired++
+		}
+		times = times[expired:]
+		if len(times) >= limit {
+			sent[key] = times
+			return errors.Wrapf(ErrQuotaExceeded, "%s: %d requests in %s, next one allowed in %s", key, limit, window, times[0].Add(window).Sub(now))
+		}
+		if len(times) == 0 {
+			// start afresh so the slice does not keep growing
+			times = nil
+		}
+		sent[key] = append(times, now)
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package port
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuota(t *testing.T) {
+	clk := newFakeClock()
+	q := Quota(3, time.Hour, nil)
+	send := func(rawurl string) error {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		require.NoError(t, err)
+		setContext(req, withClock(req.Context(), clk))
+		return q.Intercept(req)
+	}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, send("http://a.example.com"))
+		clk.Advance(10 * time.Minute)
+	}
+	err := send("http://a.example.com")
+	assert.True(t, errors.Is(err, ErrQuotaExceeded))
+	assert.Contains(t, err.Error(), "a.example.com: 3 requests in 1h0m0s, next one allowed in 30m0s")
+	// other hosts have their own budget
+	require.NoError(t, send("http://b.example.com"))
+
+	// the window slides: only the first request expires
+	clk.Advance(30 * time.Minute)
+	require.NoError(t, send("http://a.example.com"))
+	assert.True(t, errors.Is(send("http://a.example.com"), ErrQuotaExceeded))
+
+	// a whole window later, the budget is reset
+	clk.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, send("http://a.example.com"))
+	}
+}
+
+func TestQuota_ZeroLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		req, err := http.NewRequest("GET", "http://a.example.com", nil)
+		require.NoError(t, err)
+		err = Quota(limit, time.Minute, nil).Intercept(req)
+		assert.True(t, errors.Is(err, ErrQuotaExceeded), limit)
+		assert.Contains(t, err.Error(), "a.example.com: no request allowed")
+	}
+}
+
+func TestQuota_Concurrent(t *testing.T) {
+	rec := &Recorder{}
+	it := NewRequestInterceptor(rec, Quota(50, time.Hour, func(*http.Request) string { return "all" }))
+
+	var wg sync.WaitGroup
+	var rejected int32
+	for i := 0; i < 80; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://example.com", nil)
+			resp, err := it.RoundTrip(req)
+			if err != nil {
+				if errors.Is(err, ErrQuotaExceeded) {
+					atomic.AddInt32(&rejected, 1)
+				}
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(30), atomic.LoadInt32(&rejected))
+	assert.Len(t, rec.Requests(), 50)
+}
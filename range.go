@@ -0,0 +1,91 @@
+package port
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRangeNotSatisfied is returned by CheckRange when the response does not
+// hold the byte range requested
+var ErrRangeNotSatisfied = errors.New("range not satisfied")
+
+// Range returns a RequestModifier requesting the bytes from start to end
+// included with Range: bytes=start-end, e.g. to resume a download. A negative
+// end requests the bytes from start to the end of the content. Use CheckRange
+// to make sure the server honored it
+func Range(start, end int64) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if start < 0 || (end >= 0 && end < start) {
+			return errors.Errorf("invalid byte range %d-%d", start, end)
+		}
+		value := "bytes=" + strconv.FormatInt(start, 10) + "-"
+		if end >= 0 {
+			value += strconv.FormatInt(end, 10)
+		}
+		req.Header.Set("Range", value)
+		return nil
+	})
+}
+
+// CheckRange returns a ResponseModifier failing with ErrRangeNotSatisfied, and
+// closing the body, when the request had a Range header and the response is
+// not a 206 Partial Content, e.g. a server ignoring the range and sending the
+// whole content. For a single range the Content-Range of the response must
+// start where requested and not end past the requested end. Responses to
+// requests without a Range are left untouched
+func CheckRange() ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		if resp.Request == nil || resp.Request.Header.Get("Range") == "" {
+			return nil
+		}
+		err := checkRange(resp)
+		if err == nil {
+			return nil
+		}
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	})
+}
+
+func checkRange(resp *http.Response) error {
+	if resp.StatusCode != http.StatusPartialContent {
+		return errors.Wrapf(ErrRangeNotSatisfied, "status %d", resp.StatusCode)
+	}
+	start, end, ok := parseRange(strings.TrimPrefix(resp.Request.Header.Get("Range"), "bytes="))
+	if !ok {
+		// suffix or multiple ranges, the response is a 206 already
+		return nil
+	}
+	contentRange := resp.Header.Get("Content-Range")
+	spec, _, _ := strings.Cut(strings.TrimPrefix(contentRange, "bytes "), "/")
+	gotStart, gotEnd, ok := parseRange(spec)
+	if !ok || gotEnd < gotStart || gotStart != start || (end >= 0 && gotEnd > end) {
+		return errors.Wrapf(ErrRangeNotSatisfied, "content range %q for %q", contentRange, resp.Request.Header.Get("Range"))
+	}
+	return nil
+}
+
+// parseRange parses "start-end" or "start-", end being -1 for the latter
+func parseRange(s string) (start, end int64, ok bool) {
+	first, last, found := strings.Cut(strings.TrimSpace(s), "-")
+	if !found || first == "" {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if last == "" {
+		return start, -1, true
+	}
+	end, err = strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,103 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ignored" {
+			_, _ = w.Write([]byte(content))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	base := s.Client().Transport
+	get := func(path string, start, end int64) (string, string, error) {
+		c := &http.Client{Transport: NewInterceptor(base, WithRequestModifier(Range(start, end)), WithResponseModifier(CheckRange()))}
+		resp, err := c.Get(s.URL + path)
+		if err != nil {
+			return "", "", err
+		}
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.Request.Header.Get("Range"), string(b), nil
+	}
+
+	// bounded
+	header, body, err := get("/", 10, 19)
+	require.NoError(t, err)
+	assert.Equal(t, "bytes=10-19", header)
+	assert.Equal(t, "0123456789", body)
+
+	// open-ended
+	header, body, err = get("/", 95, -1)
+	require.NoError(t, err)
+	assert.Equal(t, "bytes=95-", header)
+	assert.Equal(t, "56789", body)
+
+	// the end is capped to the content
+	_, body, err = get("/", 90, 200)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", body)
+
+	// the whole content instead of the range
+	_, _, err = get("/ignored", 10, 19)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRangeNotSatisfied))
+
+	// past the end
+	_, _, err = get("/", 200, -1)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRangeNotSatisfied))
+
+	_, _, err = get("/", 20, 10)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrRangeNotSatisfied))
+}
+
+func TestCheckRange(t *testing.T) {
+	check := func(requested, status int, contentRange string) error {
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		require.NoError(t, err)
+		if requested >= 0 {
+			req.Header.Set("Range", "bytes=10-19")
+		}
+		resp := &http.Response{
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       io.NopCloser(bytes.NewReader(nil)),
+			Request:    req,
+		}
+		if contentRange != "" {
+			resp.Header.Set("Content-Range", contentRange)
+		}
+		return CheckRange().ModifyResponse(resp)
+	}
+
+	assert.NoError(t, check(0, http.StatusPartialContent, "bytes 10-19/100"))
+	assert.NoError(t, check(0, http.StatusPartialContent, "bytes 10-14/15"))
+	assert.NoError(t, check(0, http.StatusPartialContent, "bytes 10-19/*"))
+	for _, contentRange := range []string{"", "bytes 0-9/100", "bytes 10-29/100", "bytes 10-/100", "garbage"} {
+		assert.True(t, errors.Is(check(0, http.StatusPartialContent, contentRange), ErrRangeNotSatisfied), contentRange)
+	}
+	// no range requested
+	assert.NoError(t, check(-1, http.StatusOK, ""))
+}
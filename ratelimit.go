@@ -0,0 +1,130 @@
+package port
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeyByHost is a key function grouping requests by their URL host
+func KeyByHost(req *http.Request) string {
+	return req.URL.Host
+}
+
+// NewRateLimiter returns a roundtripper sending at most rps requests per
+// second, allowing bursts of up to burst requests
+func NewRateLimiter(baseTransport http.RoundTripper, rps float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Base:  baseTransport,
+		Rate:  rps,
+		Burst: burst,
+	}
+}
+
+// RateLimiter caps the request rate with a token bucket. RoundTrip blocks
+// until a token is available or the request context is done, in which case
+// the context error is returned and no token is consumed. Once closed, waiting
+// and new requests fail with ErrClosed
+type RateLimiter struct {
+	Base http.RoundTripper
+	// Rate is the number of requests allowed per second, a zero or negative
+	// rate disables the limit
+	Rate float64
+	// Burst is the size of the bucket, at least 1
+	Burst int
+	// Key, if set, gives every key its own bucket, e.g. KeyByHost for a per
+	// host limit. All requests share the same bucket otherwise
+	Key func(req *http.Request) string
+
+	mu       sync.Mutex // guards buckets
+	buckets  map[string]*bucket
+	shutdown shutdown
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RoundTrip waits for a token then sends the request
+func (l *RateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := ""
+	if l.Key != nil {
+		key = l.Key(req)
+	}
+	if l.shutdown.isDone() {
+		closeBody(req)
+		return nil, ErrClosed
+	}
+	clk := clockFrom(req.Context())
+	for {
+		wait := l.take(key, clk.Now())
+		if wait <= 0 {
+			break
+		}
+		select {
+		case <-req.Context().Done():
+			closeBody(req)
+			return nil, req.Context().Err()
+		case <-l.shutdown.closed():
+			closeBody(req)
+			return nil, ErrClosed
+		case <-clk.After(wait):
+		}
+	}
+	return l.base().RoundTrip(req)
+}
+
+// take consumes a token of the key bucket, or returns how long to wait before
+// one is available, at time now
+func (l *RateLimiter) take(key string, now time.Time) time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// Close makes the waiting and future requests fail with ErrClosed. It can be
+// called several times
+func (l *RateLimiter) Close() error {
+	l.shutdown.close()
+	return nil
+}
+
+// Wrap sets base as the Base of RateLimiter, so it can be used with Stack
+func (l *RateLimiter) Wrap(base http.RoundTripper) http.RoundTripper {
+	l.Base = base
+	return l
+}
+
+func (l *RateLimiter) base() http.RoundTripper {
+	if l.Base != nil {
+		return l.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,81 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRateLimiter(c.Transport, 20, 2)
+
+	st := time.Now()
+	for i := 0; i < 6; i++ {
+		resp, err := c.Get(s.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	// 2 requests from the burst, then 4 at 20 per second
+	assert.True(t, time.Since(st) >= 190*time.Millisecond, time.Since(st).String())
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	l := NewRateLimiter(s.Client().Transport, 1, 1)
+	c := &http.Client{Transport: l}
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	cctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+
+	st := time.Now()
+	_, err = c.Do(req.WithContext(cctx))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.WithinDuration(t, time.Now(), st, 200*time.Millisecond)
+
+	// the canceled request did not consume the token refilled meanwhile
+	time.Sleep(time.Second)
+	assert.Equal(t, time.Duration(0), l.take("", time.Now()))
+}
+
+func TestRateLimiter_PerHost(t *testing.T) {
+	s1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s1.Close()
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s2.Close()
+
+	l := NewRateLimiter(nil, 0.1, 1)
+	l.Key = KeyByHost
+	c := &http.Client{Transport: l}
+
+	st := time.Now()
+	for _, u := range []string{s1.URL, s2.URL} {
+		resp, err := c.Get(u)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.WithinDuration(t, time.Now(), st, 500*time.Millisecond)
+}
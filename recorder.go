@@ -0,0 +1,89 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RoundTripFunc is used to transform a simple function as a http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip sends the request with the RoundTripFunc function
+func (r RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r(req)
+}
+
+// Recorder is a roundtripper keeping a copy of every request it receives, it
+// is meant to be used as the base transport of modifiers under test. Recorded
+// requests own a copy of their body, which can be read after RoundTrip
+// returned.
+//
+// Requests are then passed to Base. Unlike other transports of this package,
+// a nil Base does not reach the network: an empty 200 response is returned
+type Recorder struct {
+	Base http.RoundTripper
+
+	mu       sync.Mutex // guards requests
+	requests []*http.Request
+}
+
+// RoundTrip records the request then sends it to Base
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := cloneRequest(req)
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to record request body")
+		}
+		setBody(rec, b)
+		rec.ContentLength = req.ContentLength
+
+		forwarded := new(http.Request)
+		*forwarded = *req
+		forwarded.Body = io.NopCloser(bytes.NewReader(b))
+		req = forwarded
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, rec)
+	r.mu.Unlock()
+
+	if r.Base != nil {
+		return r.Base.RoundTrip(req)
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// Wrap sets base as the Base of Recorder, so it can be used with Stack
+func (r *Recorder) Wrap(base http.RoundTripper) http.RoundTripper {
+	r.Base = base
+	return r
+}
+
+// Requests returns the requests recorded so far, in order
+func (r *Recorder) Requests() []*http.Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*http.Request(nil), r.requests...)
+}
+
+// Reset forgets the recorded requests
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = nil
+}
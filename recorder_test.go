@@ -0,0 +1,53 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := &Recorder{}
+	it := NewRequestInterceptor(rec, BearerToken("t0k3n"))
+
+	req, err := http.NewRequest("POST", "http://example.com/items", strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err := it.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	requests := rec.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "Bearer t0k3n", requests[0].Header.Get("Authorization"))
+	assert.Equal(t, "/items", requests[0].URL.Path)
+	b, err := io.ReadAll(requests[0].Body)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(b))
+
+	rec.Reset()
+	assert.Empty(t, rec.Requests())
+}
+
+func TestRecorder_Base(t *testing.T) {
+	var forwarded string
+	rec := &Recorder{Base: RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		forwarded = string(b)
+		return &http.Response{StatusCode: http.StatusTeapot, Body: http.NoBody, Request: req}, nil
+	})}
+
+	req, err := http.NewRequest("PUT", "http://example.com", strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err := rec.RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "payload", forwarded)
+	b, err := io.ReadAll(rec.Requests()[0].Body)
+	require.NoError(t, err)
+	assert.Equal(t, "payload", string(b))
+}
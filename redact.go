@@ -0,0 +1,69 @@
+package port
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultRedactedQueryParams are the query parameters always hidden by SafeURL,
+// compared case-insensitively
+var DefaultRedactedQueryParams = []string{"access_token", "api_key", "apikey", "password", "secret", "token", "X-Amz-Credential", "X-Amz-Security-Token", "X-Amz-Signature"}
+
+type redactedParamsKey struct{}
+
+// RedactQueryParams returns a RequestModifier adding keys to the query
+// parameters hidden by SafeURL, and thus by Dumper, for the request. The
+// request sent keeps the real values
+func RedactQueryParams(keys ...string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if len(keys) == 0 {
+			return nil
+		}
+		existing := redactedParams(req.Context())
+		params := make([]string, len(existing), len(existing)+len(keys))
+		copy(params, existing)
+		setContext(req, context.WithValue(req.Context(), redactedParamsKey{}, append(params, keys...)))
+		return nil
+	})
+}
+
+func redactedParams(ctx context.Context) []string {
+	params, _ := ctx.Value(redactedParamsKey{}).([]string)
+	return params
+}
+
+// SafeURL returns the URL of req suitable for logs: the values of
+// DefaultRedactedQueryParams and of the parameters given to RedactQueryParams
+// are replaced with [REDACTED], and the password of the user info is hidden
+// like url.URL.Redacted does
+func SafeURL(req *http.Request) string {
+	return safeURL(req).String()
+}
+
+// safeURL returns a redacted copy of req.URL
+func safeURL(req *http.Request) *url.URL {
+	u := new(url.URL)
+	*u = *req.URL
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	if u.RawQuery == "" {
+		return u
+	}
+	extra := redactedParams(req.Context())
+	parts := strings.Split(u.RawQuery, "&")
+	for i, part := range parts {
+		rawKey, _, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if containsFold(DefaultRedactedQueryParams, key) || containsFold(extra, key) {
+			parts[i] = rawKey + "=" + redacted
+		}
+	}
+	u.RawQuery = strings.Join(parts, "&")
+	return u
+}
@@ -0,0 +1,154 @@
+package port
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTooManyRedirects is returned by RedirectFollower when a request is
+// redirected more than MaxHops times
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// sensitiveRedirectHeaders are removed from requests redirected to another
+// host
+var sensitiveRedirectHeaders = []string{"Authorization", "Proxy-Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+// NewRedirectFollower returns a roundtripper following up to maxHops
+// redirects
+func NewRedirectFollower(baseTransport http.RoundTripper, maxHops int) *RedirectFollower {
+	return &RedirectFollower{
+		Base:    baseTransport,
+		MaxHops: maxHops,
+	}
+}
+
+// RedirectFollower follows the redirects returned by Base, like http.Client
+// does, so transports stacked above it only see final responses. 301, 302 and
+// 303 redirects are followed with a GET without body, 307 and 308 ones with
+// the same method and the body replayed with GetBody: without GetBody the
+// redirect response is returned as is. Credentials and cookies are removed
+// when the host changes. Every hop uses the context of the original request
+type RedirectFollower struct {
+	Base http.RoundTripper
+	// MaxHops is the number of redirects followed before failing with
+	// ErrTooManyRedirects, 10 by default
+	MaxHops int
+	// CheckRedirect, if set, is called before following a redirect with the
+	// next request and the requests made so far, oldest first. Returning
+	// http.ErrUseLastResponse hands the redirect response to the caller, any
+	// other error fails the request
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// RoundTrip sends the request, following the redirects returned
+func (t *RedirectFollower) RoundTrip(req *http.Request) (*http.Response, error) {
+	var via []*http.Request
+	current := req
+	for {
+		res, err := t.base().RoundTrip(current)
+		if err != nil {
+			return nil, err
+		}
+		next := redirectRequest(current, res)
+		if next == nil {
+			return res, nil
+		}
+		if len(via) >= t.maxHops() {
+			discardResponse(res)
+			closeBody(next)
+			return nil, errors.Wrapf(ErrTooManyRedirects, "stopped after %d redirects", len(via))
+		}
+		via = append(via, current)
+		if t.CheckRedirect != nil {
+			if err := t.CheckRedirect(next, via); err != nil {
+				closeBody(next)
+				if err == http.ErrUseLastResponse {
+					return res, nil
+				}
+				discardResponse(res)
+				return nil, err
+			}
+		}
+		discardResponse(res)
+		if err := req.Context().Err(); err != nil {
+			closeBody(next)
+			return nil, err
+		}
+		current = next
+	}
+}
+
+// redirectRequest returns the request following the redirect res, nil when
+// res is not a redirect that can be followed
+func redirectRequest(req *http.Request, res *http.Response) *http.Request {
+	includeBody := false
+	method := req.Method
+	switch res.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != http.MethodGet && method != http.MethodHead && method != "" {
+			method = http.MethodGet
+		}
+	case http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		includeBody = true
+	default:
+		return nil
+	}
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return nil
+	}
+	u, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil
+	}
+
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if includeBody && hasBody && req.GetBody == nil {
+		return nil
+	}
+	next := req.Clone(req.Context())
+	next.Method = method
+	next.URL = u
+	next.Host = ""
+	if includeBody && hasBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil
+		}
+		next.Body = body
+	} else {
+		next.Body = nil
+		next.GetBody = nil
+		next.ContentLength = 0
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+	if !strings.EqualFold(u.Host, req.URL.Host) {
+		for _, h := range sensitiveRedirectHeaders {
+			next.Header.Del(h)
+		}
+	}
+	return next
+}
+
+func (t *RedirectFollower) maxHops() int {
+	if t.MaxHops > 0 {
+		return t.MaxHops
+	}
+	return 10
+}
+
+// Wrap sets base as the Base of RedirectFollower, so it can be used with Stack
+func (t *RedirectFollower) Wrap(base http.RoundTripper) http.RoundTripper {
+	t.Base = base
+	return t
+}
+
+func (t *RedirectFollower) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,126 @@
+package port
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectFollower(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusTemporaryRedirect)
+		default:
+			b, _ := io.ReadAll(r.Body)
+			_, _ = fmt.Fprintf(w, "%s %s %s %q", r.Method, r.URL.Path, r.Header.Get("Authorization"), b)
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	var hops []string
+	rf := NewRedirectFollower(s.Client().Transport, 0)
+	rf.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		hops = append(hops, req.URL.Path)
+		return nil
+	}
+
+	// the 302 turns the POST into a GET without body
+	req, err := http.NewRequest("POST", s.URL+"/a", strings.NewReader("payload"))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := rf.RoundTrip(req)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, `GET /c Bearer token ""`, string(b))
+	assert.Equal(t, []string{"/b", "/c"}, hops)
+
+	// the 307 replays the body
+	req, err = http.NewRequest("PUT", s.URL+"/b", strings.NewReader("payload"))
+	require.NoError(t, err)
+	resp, err = rf.RoundTrip(req)
+	require.NoError(t, err)
+	b, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, `PUT /c  "payload"`, string(b))
+}
+
+func TestRedirectFollower_CrossHost(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, "auth=%q cookie=%q custom=%q", r.Header.Get("Authorization"), r.Header.Get("Cookie"), r.Header.Get("X-Custom"))
+	}))
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/landing", http.StatusMovedPermanently)
+	}))
+
+	defer func() {
+		s.Close()
+		other.Close()
+	}()
+
+	c := &http.Client{Transport: NewRedirectFollower(s.Client().Transport, 3)}
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("X-Custom", "kept")
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, `auth="" cookie="" custom="kept"`, string(b))
+}
+
+func TestRedirectFollower_MaxHops(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusFound)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	req, err := http.NewRequest("GET", s.URL+"/", nil)
+	require.NoError(t, err)
+	_, err = NewRedirectFollower(s.Client().Transport, 3).RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrTooManyRedirects))
+	assert.Contains(t, err.Error(), "stopped after 3 redirects")
+}
+
+func TestRedirectFollower_UseLastResponse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	rf := NewRedirectFollower(s.Client().Transport, 3)
+	rf.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	resp, err := rf.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+}
@@ -0,0 +1,53 @@
+package port
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header used by RequestID when none is given
+const DefaultRequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID returns a RequestModifier setting a unique identifier in header
+// (DefaultRequestIDHeader if empty) using gen (a UUIDv4 generator if nil). An
+// identifier already present on the request is kept so upstream ids
+// propagate. The identifier sent is stored in the request context and can be
+// retrieved with RequestIDFromContext
+func RequestID(header string, gen func() string) RequestModifier {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	if gen == nil {
+		gen = newUUID
+	}
+	return RequestModifierFunc(func(req *http.Request) error {
+		id := req.Header.Get(header)
+		if id == "" {
+			id = gen()
+			req.Header.Set(header, id)
+		}
+		setContext(req, context.WithValue(req.Context(), requestIDKey{}, id))
+		return nil
+	})
+}
+
+// RequestIDFromContext returns the request id stored by the RequestID modifier
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
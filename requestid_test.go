@@ -0,0 +1,70 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(DefaultRequestIDHeader)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, RequestID("", nil))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Regexp(t, uuidPattern, received)
+	id, ok := RequestIDFromContext(resp.Request.Context())
+	require.True(t, ok)
+	assert.Equal(t, received, id)
+}
+
+func TestRequestID_Preserve(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Correlation-ID", "upstream")
+
+	err = RequestID("X-Correlation-ID", func() string { return "generated" }).Intercept(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "upstream", req.Header.Get("X-Correlation-ID"))
+	id, ok := RequestIDFromContext(req.Context())
+	require.True(t, ok)
+	assert.Equal(t, "upstream", id)
+}
+
+func TestRequestID_Generator(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	err = RequestID("", func() string { return "generated" }).Intercept(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "generated", req.Header.Get(DefaultRequestIDHeader))
+	id, _ := RequestIDFromContext(req.Context())
+	assert.Equal(t, "generated", id)
+}
+
+func TestRequestIDFromContext_Missing(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	_, ok := RequestIDFromContext(req.Context())
+	assert.False(t, ok)
+}
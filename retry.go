@@ -0,0 +1,324 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrBodyNotReplayable is returned when a request has to be sent again but its
+// body cannot be read a second time (req.GetBody is nil)
+var ErrBodyNotReplayable = errors.New("request body is not replayable")
+
+// DefaultRetryStatuses are the status codes retried by RetryTransport when
+// RetryStatuses is empty. 429 Too Many Requests is among them, the server
+// asking with Retry-After when to try again
+var DefaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// DefaultRetryPeekSize is the number of response body bytes RetryOnResponse
+// sees when RetryPeekSize is zero
+const DefaultRetryPeekSize = 64 << 10
+
+// DefaultMaxRetryAfter is the longest wait RetryTransport accepts from a
+// Retry-After header when MaxRetryAfter is zero
+const DefaultMaxRetryAfter = 30 * time.Second
+
+// ExponentialBackoff returns a backoff function doubling the delay on each
+// attempt, starting at base and never exceeding max
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// NewRetryTransport returns a roundtripper retrying idempotent requests up to
+// maxRetries times, waiting backoff(attempt) between two attempts
+func NewRetryTransport(baseTransport http.RoundTripper, maxRetries int, backoff func(attempt int) time.Duration) *RetryTransport {
+	return &RetryTransport{
+		Base:       baseTransport,
+		MaxRetries: maxRetries,
+		Backoff:    backoff,
+	}
+}
+
+// RetryTransport retries idempotent requests on connection errors and on
+// retryable status codes. Requests sent with an idempotency key, see
+// IdempotencyKey, are retried whatever their method. The request body is
+// replayed with req.GetBody on every new attempt
+type RetryTransport struct {
+	Base http.RoundTripper
+	// MaxRetries is the number of attempts made after the first one
+	MaxRetries int
+	// RetryStatuses lists the status codes triggering a retry, it defaults to
+	// DefaultRetryStatuses
+	RetryStatuses []int
+	// Backoff returns the delay to wait before the given retry attempt,
+	// starting at 1. It defaults to an exponential backoff from 100ms to 5s.
+	// When their status is retried, 429 and 503 responses carrying a
+	// Retry-After header are retried after the delay it gives instead
+	Backoff func(attempt int) time.Duration
+	// MaxRetryAfter caps the delay taken from a Retry-After header,
+	// DefaultMaxRetryAfter by default
+	MaxRetryAfter time.Duration
+	// RetryOnResponse, if set, is called with the responses whose status is
+	// not retried, to retry e.g. a 200 carrying an error envelope. Its body
+	// holds the first RetryPeekSize bytes only, the response handed to the
+	// caller is left whole. An error fails the request with it
+	RetryOnResponse func(res *http.Response) (bool, error)
+	// RetryPeekSize bounds the body read for RetryOnResponse,
+	// DefaultRetryPeekSize by default
+	RetryPeekSize int64
+}
+
+// RetryPolicy overrides the settings of RetryTransport for a request, see
+// WithRetryPolicy
+type RetryPolicy struct {
+	// MaxRetries replaces RetryTransport.MaxRetries, zero disables retries
+	MaxRetries int
+	// RetryStatuses, if set, replaces RetryTransport.RetryStatuses
+	RetryStatuses []int
+	// Backoff, if set, replaces RetryTransport.Backoff
+	Backoff func(attempt int) time.Duration
+}
+
+// NoRetry is the RetryPolicy of the requests which must not be retried
+var NoRetry = RetryPolicy{}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns a copy of ctx carrying policy, which RetryTransport
+// applies to the requests made with it instead of its own settings
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// withPolicy returns a copy of t with the settings of policy
+func (t *RetryTransport) withPolicy(policy RetryPolicy) *RetryTransport {
+	t2 := *t
+	t2.MaxRetries = policy.MaxRetries
+	if policy.RetryStatuses != nil {
+		t2.RetryStatuses = policy.RetryStatuses
+	}
+	if policy.Backoff != nil {
+		t2.Backoff = policy.Backoff
+	}
+	return &t2
+}
+
+// RoundTrip sends the request, retrying it while it fails and retries remain
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if policy, ok := req.Context().Value(retryPolicyKey{}).(RetryPolicy); ok {
+		t = t.withPolicy(policy)
+	}
+	// every attempt shares the same idempotency key holder, so an
+	// IdempotencyKey modifier below this transport sends the same key each time
+	ctx, holder := withIdempotencyHolder(req.Context())
+	if ctx != req.Context() {
+		req = req.WithContext(ctx)
+	}
+
+	clk := clockFrom(ctx)
+	current := req
+	for attempt := 0; ; attempt++ {
+		res, err := t.base().RoundTrip(current)
+		if ctx.Err() != nil {
+			// the caller gave up, there is no point in retrying
+			return res, err
+		}
+		if attempt >= t.MaxRetries || !retryable(req, holder) {
+			return res, err
+		}
+		retry, perr := t.shouldRetry(res, err)
+		if perr != nil {
+			discardResponse(res)
+			return nil, perr
+		}
+		if !retry {
+			return res, err
+		}
+
+		delay := t.backoff(attempt + 1)
+		if d, ok := retryAfter(res, clk.Now()); ok {
+			delay = min(d, t.maxRetryAfter())
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(clk.Now()) < delay {
+			// the next attempt could not complete in time
+			return res, err
+		}
+
+		next, rerr := rewindRequest(req)
+		if rerr != nil {
+			discardResponse(res)
+			return nil, rerr
+		}
+		discardResponse(res)
+
+		select {
+		case <-ctx.Done():
+			closeBody(next)
+			return nil, ctx.Err()
+		case <-clk.After(delay):
+		}
+		current = next
+	}
+}
+
+func (t *RetryTransport) shouldRetry(res *http.Response, err error) (bool, error) {
+	if err != nil {
+		return true, nil
+	}
+	statuses := t.RetryStatuses
+	if len(statuses) == 0 {
+		statuses = DefaultRetryStatuses
+	}
+	for _, s := range statuses {
+		if res.StatusCode == s {
+			return true, nil
+		}
+	}
+	if t.RetryOnResponse == nil {
+		return false, nil
+	}
+	return t.peekResponse(res)
+}
+
+// peekResponse calls RetryOnResponse with a copy of res holding the start of
+// its body, res.Body is restored to the whole body
+func (t *RetryTransport) peekResponse(res *http.Response) (bool, error) {
+	peek := &http.Response{}
+	*peek = *res
+	if res.Body != nil && res.Body != http.NoBody {
+		size := t.RetryPeekSize
+		if size <= 0 {
+			size = DefaultRetryPeekSize
+		}
+		b, err := io.ReadAll(io.LimitReader(res.Body, size))
+		if err != nil {
+			return false, errors.Wrap(err, "unable to read response body")
+		}
+		res.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(b), res.Body), Closer: res.Body}
+		peek.Body = io.NopCloser(bytes.NewReader(b))
+	}
+	retry, err := t.RetryOnResponse(peek)
+	if err != nil {
+		return false, errors.Wrap(err, "retry predicate failed")
+	}
+	return retry, nil
+}
+
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	if t.Backoff != nil {
+		return t.Backoff(attempt)
+	}
+	return ExponentialBackoff(100*time.Millisecond, 5*time.Second)(attempt)
+}
+
+// Wrap sets base as the Base of RetryTransport, so it can be used with Stack
+func (t *RetryTransport) Wrap(base http.RoundTripper) http.RoundTripper {
+	t.Base = base
+	return t
+}
+
+func (t *RetryTransport) maxRetryAfter() time.Duration {
+	if t.MaxRetryAfter > 0 {
+		return t.MaxRetryAfter
+	}
+	return DefaultMaxRetryAfter
+}
+
+// retryAfter returns the delay asked by the Retry-After header of a 429 or
+// 503 response, given either in seconds or as an HTTP date relative to now
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if res == nil || (res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	v := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > int64(math.MaxInt64/time.Second) {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// isIdempotent reports whether the request can safely be sent several times
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether req can be sent again: its method is idempotent
+// or it carries an idempotency key
+func retryable(req *http.Request, holder *idempotencyHolder) bool {
+	return isIdempotent(req) || req.Header.Get(DefaultIdempotencyKeyHeader) != "" || holder.get() != ""
+}
+
+// rewindRequest returns a shallow copy of req with a fresh body obtained from
+// req.GetBody, ready to be sent again
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	r2 := new(http.Request)
+	*r2 = *req
+	if req.Body == nil || req.Body == http.NoBody {
+		return r2, nil
+	}
+	if req.GetBody == nil {
+		return nil, ErrBodyNotReplayable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to replay request body")
+	}
+	r2.Body = body
+	return r2, nil
+}
+
+// discardResponse drains a bit of the response body so the connection can be
+// reused, then closes it
+func discardResponse(res *http.Response) {
+	if res == nil || res.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(io.Discard, res.Body, 4096)
+	_ = res.Body.Close()
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
@@ -0,0 +1,433 @@
+package port
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noBackoff(int) time.Duration { return 0 }
+
+func TestRetryTransport_RoundTrip(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if string(b) != "payload" {
+			t.Errorf("unexpected body %q", b)
+		}
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 3, noBackoff)
+
+	req, err := http.NewRequest("PUT", s.URL, bytes.NewReader([]byte("payload")))
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryTransport_RoundTrip_Exhausted(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 2, noBackoff)
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryTransport_RoundTrip_NonIdempotent(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 3, noBackoff)
+
+	resp, err := c.Post(s.URL, "text/plain", strings.NewReader("payload"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryTransport_RoundTrip_NonReplayableBody(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 3, noBackoff)
+
+	req, err := http.NewRequest("PUT", s.URL, io.NopCloser(strings.NewReader("payload")))
+	require.NoError(t, err)
+	_, err = c.Do(req)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBodyNotReplayable))
+}
+
+func TestRetryTransport_RoundTrip_ConnectionError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := s.URL
+	s.Close()
+
+	var calls int32
+	tr := NewRetryTransport(http.DefaultTransport, 2, func(attempt int) time.Duration {
+		atomic.AddInt32(&calls, 1)
+		return 0
+	})
+
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	_, err = tr.RoundTrip(req)
+	require.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRetryTransport_RoundTrip_Context(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 3, func(int) time.Duration { return time.Hour })
+
+	cctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	req = req.WithContext(cctx)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	st := time.Now()
+	_, err = c.Do(req)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.WithinDuration(t, time.Now(), st, time.Second)
+}
+
+func TestRetryTransport_RoundTrip_Deadline(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 3, func(int) time.Duration { return time.Hour })
+
+	cctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+
+	resp, err := c.Do(req.WithContext(cctx))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(100*time.Millisecond, time.Second)
+	assert.Equal(t, 100*time.Millisecond, b(1))
+	assert.Equal(t, 200*time.Millisecond, b(2))
+	assert.Equal(t, 400*time.Millisecond, b(3))
+	assert.Equal(t, time.Second, b(10))
+}
+
+func retryAfterServer(status int, value string) (*httptest.Server, *int32) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", value)
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return s, &calls
+}
+
+func TestRetryTransport_RetryAfter_Seconds(t *testing.T) {
+	s, calls := retryAfterServer(http.StatusServiceUnavailable, "3600")
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	rt := NewRetryTransport(c.Transport, 1, noBackoff)
+	rt.MaxRetryAfter = 100 * time.Millisecond
+	c.Transport = rt
+
+	st := time.Now()
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	// the hour asked is capped
+	elapsed := time.Since(st)
+	assert.True(t, elapsed >= 100*time.Millisecond, elapsed.String())
+	assert.True(t, elapsed < time.Second, elapsed.String())
+}
+
+func TestRetryTransport_RetryAfter_Date(t *testing.T) {
+	s, calls := retryAfterServer(http.StatusTooManyRequests, time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	// a date in the past means no wait, whatever the backoff
+	c.Transport = NewRetryTransport(c.Transport, 1, func(int) time.Duration { return time.Hour })
+
+	st := time.Now()
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.True(t, time.Since(st) < time.Second)
+}
+
+func TestRetryTransport_RetryAfter_Deadline(t *testing.T) {
+	s, calls := retryAfterServer(http.StatusServiceUnavailable, time.Now().Add(10*time.Second).UTC().Format(http.TimeFormat))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 1, noBackoff)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+	require.NoError(t, err)
+	st := time.Now()
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// the retry could not happen before the deadline, the 503 is returned
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	assert.True(t, time.Since(st) < 500*time.Millisecond)
+}
+
+func TestRetryTransport_TooManyRequestsDefault(t *testing.T) {
+	s, calls := retryAfterServer(http.StatusTooManyRequests, "1")
+
+	defer func() {
+		s.Close()
+	}()
+
+	// a default transport waits for the Retry-After of a 429, not the backoff
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 1, func(int) time.Duration { return time.Hour })
+
+	st := time.Now()
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	elapsed := time.Since(st)
+	assert.True(t, elapsed >= time.Second, elapsed.String())
+	assert.True(t, elapsed < 2*time.Second, elapsed.String())
+}
+
+func TestRetryAfter(t *testing.T) {
+	resp := func(status int, value string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": {value}}}
+	}
+	d, ok := retryAfter(resp(http.StatusServiceUnavailable, "120"), time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, d)
+
+	d, ok = retryAfter(resp(http.StatusTooManyRequests, time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)), time.Now())
+	assert.True(t, ok)
+	assert.InDelta(t, time.Minute, d, float64(2*time.Second))
+
+	for _, r := range []*http.Response{
+		nil,
+		resp(http.StatusBadGateway, "120"),
+		resp(http.StatusServiceUnavailable, ""),
+		resp(http.StatusServiceUnavailable, "-1"),
+		resp(http.StatusServiceUnavailable, "soon"),
+	} {
+		_, ok := retryAfter(r, time.Now())
+		assert.False(t, ok)
+	}
+}
+
+func errorEnvelope(res *http.Response) (bool, error) {
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(b), `"error"`), nil
+}
+
+func TestRetryTransport_RetryOnResponse(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			_, _ = io.WriteString(w, `{"error":"busy"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"result":"`+strings.Repeat("x", 100)+`"}`)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	rt := NewRetryTransport(c.Transport, 3, noBackoff)
+	rt.RetryOnResponse = errorEnvelope
+	rt.RetryPeekSize = 16
+	c.Transport = rt
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	// the predicate only peeked, the caller gets the whole body
+	assert.Equal(t, `{"result":"`+strings.Repeat("x", 100)+`"}`, string(b))
+
+	// a clean response is not retried
+	atomic.StoreInt32(&calls, 10)
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(11), atomic.LoadInt32(&calls))
+}
+
+func TestRetryTransport_RetryOnResponse_Exhausted(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = io.WriteString(w, `{"error":"busy"}`)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	rt := NewRetryTransport(c.Transport, 2, noBackoff)
+	rt.RetryOnResponse = errorEnvelope
+	c.Transport = rt
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, `{"error":"busy"}`, string(b))
+
+	// a failing predicate fails the request
+	rt.RetryOnResponse = func(*http.Response) (bool, error) { return false, errors.New("bad envelope") }
+	_, err = c.Get(s.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retry predicate failed: bad envelope")
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRetryTransport(c.Transport, 2, noBackoff)
+	send := func(ctx context.Context) int32 {
+		atomic.StoreInt32(&calls, 0)
+		req, err := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+		require.NoError(t, err)
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		return atomic.LoadInt32(&calls)
+	}
+
+	// the default applies without policy
+	assert.Equal(t, int32(3), send(context.Background()))
+	assert.Equal(t, int32(1), send(WithRetryPolicy(context.Background(), NoRetry)))
+	assert.Equal(t, int32(5), send(WithRetryPolicy(context.Background(), RetryPolicy{MaxRetries: 4})))
+	// 502 is not retried by this policy
+	assert.Equal(t, int32(1), send(WithRetryPolicy(context.Background(), RetryPolicy{MaxRetries: 4, RetryStatuses: []int{http.StatusServiceUnavailable}})))
+	assert.Equal(t, int32(3), send(context.Background()))
+}
@@ -0,0 +1,65 @@
+package port
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// NewHostRouter returns a HostRouter sending requests to unregistered hosts
+// through baseTransport
+func NewHostRouter(baseTransport http.RoundTripper) *HostRouter {
+	return &HostRouter{Base: baseTransport}
+}
+
+// HostRouter selects the transport of a request from its URL host. Hosts are
+// matched case-insensitively, including the port unless MatchHostname is set
+type HostRouter struct {
+	// Base is used for the hosts without a registered transport
+	Base http.RoundTripper
+	// MatchHostname matches hosts without their port, "example.com" then
+	// routes both example.com:80 and example.com:8443
+	MatchHostname bool
+
+	mu     sync.RWMutex // guards routes
+	routes map[string]http.RoundTripper
+}
+
+// Handle registers the transport used for host
+func (r *HostRouter) Handle(host string, rt http.RoundTripper) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.routes == nil {
+		r.routes = make(map[string]http.RoundTripper)
+	}
+	r.routes[strings.ToLower(host)] = rt
+}
+
+// RoundTrip sends the request through the transport registered for its host
+func (r *HostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.transport(req).RoundTrip(req)
+}
+
+// Wrap sets base as the Base of HostRouter, used for the hosts without a
+// registered transport
+func (r *HostRouter) Wrap(base http.RoundTripper) http.RoundTripper {
+	r.Base = base
+	return r
+}
+
+func (r *HostRouter) transport(req *http.Request) http.RoundTripper {
+	host := req.URL.Host
+	if r.MatchHostname {
+		host = req.URL.Hostname()
+	}
+	r.mu.RLock()
+	rt, ok := r.routes[strings.ToLower(host)]
+	r.mu.RUnlock()
+	if ok && rt != nil {
+		return rt
+	}
+	if r.Base != nil {
+		return r.Base
+	}
+	return http.DefaultTransport
+}
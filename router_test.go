@@ -0,0 +1,58 @@
+package port
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostRouter(t *testing.T) {
+	api := &Recorder{}
+	admin := &Recorder{}
+	fallback := &Recorder{}
+
+	r := NewHostRouter(fallback)
+	r.Handle("api.example.com", api)
+	r.Handle("Admin.example.com:8443", admin)
+
+	for _, u := range []string{
+		"https://api.example.com/a",
+		"https://API.EXAMPLE.COM/b",
+		"https://admin.example.com:8443/c",
+		"https://admin.example.com/d",
+		"https://api.example.com:8080/e",
+	} {
+		req, err := http.NewRequest("GET", u, nil)
+		require.NoError(t, err)
+		_, err = r.RoundTrip(req)
+		require.NoError(t, err)
+	}
+
+	paths := func(rec *Recorder) []string {
+		var p []string
+		for _, req := range rec.Requests() {
+			p = append(p, req.URL.Path)
+		}
+		return p
+	}
+	assert.Equal(t, []string{"/a", "/b"}, paths(api))
+	assert.Equal(t, []string{"/c"}, paths(admin))
+	assert.Equal(t, []string{"/d", "/e"}, paths(fallback))
+}
+
+func TestHostRouter_MatchHostname(t *testing.T) {
+	api := &Recorder{}
+	r := NewHostRouter(&Recorder{})
+	r.MatchHostname = true
+	r.Handle("api.example.com", api)
+
+	for _, u := range []string{"https://api.example.com/", "http://api.example.com:8080/"} {
+		req, err := http.NewRequest("GET", u, nil)
+		require.NoError(t, err)
+		_, err = r.RoundTrip(req)
+		require.NoError(t, err)
+	}
+	assert.Len(t, api.Requests(), 2)
+}
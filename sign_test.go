@@ -0,0 +1,120 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDate = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+func TestSignHMAC(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com/v1/items?a=1", strings.NewReader(`{"hello":"world"}`))
+	require.NoError(t, err)
+	req.Header.Set("Date", testDate)
+	req.Header.Set("X-Tenant", "acme")
+
+	mod := SignHMAC("key-1", []byte("secret"), SignOptions{SignedHeaders: []string{"Date", "X-Tenant"}})
+	require.NoError(t, mod.Intercept(req))
+
+	assert.Equal(t, `HMAC-SHA256 keyId="key-1",headers="date x-tenant",signature="4Q1jkxDMQsioMwizpAeQAM9uQwrMUMOL/ZW7heA+ptw="`, req.Header.Get("Authorization"))
+
+	// the body is still readable, and replayable
+	b, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(b))
+	require.NotNil(t, req.GetBody)
+	rc, err := req.GetBody()
+	require.NoError(t, err)
+	b, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hello":"world"}`, string(b))
+}
+
+func TestSignHMAC_Defaults(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", testDate)
+
+	require.NoError(t, SignHMAC("key-1", []byte("secret"), SignOptions{Header: "X-Signature"}).Intercept(req))
+	assert.Equal(t, `HMAC-SHA256 keyId="key-1",headers="date",signature="QIQta6Gf1oSodASfvxRlSIJy5ybcc03U/2iZ4ara6iw="`, req.Header.Get("X-Signature"))
+	assert.Empty(t, req.Header.Get("Authorization"))
+}
+
+func TestSignHMAC_Transport(t *testing.T) {
+	var body string
+	var date, signature string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		date = r.Header.Get("Date")
+		signature = r.Header.Get("Authorization")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, SignHMAC("key-1", []byte("secret"), SignOptions{}))
+
+	resp, err := c.Post(s.URL, "application/json", strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, `{"a":1}`, body)
+	assert.NotEmpty(t, date)
+	assert.True(t, strings.HasPrefix(signature, `HMAC-SHA256 keyId="key-1"`))
+}
+
+func TestSignHMAC_BodyTooLarge(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.com/", strings.NewReader("0123456789"))
+	require.NoError(t, err)
+
+	err = SignHMAC("key-1", []byte("secret"), SignOptions{MaxBodySize: 5}).Intercept(req)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func TestReplayProtection(t *testing.T) {
+	type seen struct{ nonce, ts, auth string }
+	var requests []seen
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, seen{r.Header.Get("X-Nonce"), r.Header.Get("X-Timestamp"), r.Header.Get("Authorization")})
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	clk := newFakeClock()
+	c := s.Client()
+	it := NewInterceptor(c.Transport, WithClock(clk), WithRequestModifier(ChainModifiers(
+		ReplayProtection("X-Nonce", "X-Timestamp", nil),
+		SignHMAC("key-1", []byte("secret"), SignOptions{SignedHeaders: []string{"X-Nonce", "X-Timestamp"}}),
+	)))
+	// retried above the interceptor, every attempt gets a new nonce
+	c.Transport = NewRetryTransport(it, 1, noBackoff)
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Len(t, requests, 2)
+	for _, r := range requests {
+		assert.Len(t, r.nonce, 36)
+		assert.Equal(t, "1577836800", r.ts)
+		assert.Contains(t, r.auth, `headers="x-nonce x-timestamp"`)
+	}
+	assert.NotEqual(t, requests[0].nonce, requests[1].nonce)
+	assert.NotEqual(t, requests[0].auth, requests[1].auth)
+}
@@ -0,0 +1,119 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// NewSingleflight returns a roundtripper coalescing concurrent identical GET
+// and HEAD requests
+func NewSingleflight(baseTransport http.RoundTripper) *Singleflight {
+	return &Singleflight{Base: baseTransport}
+}
+
+// Singleflight sends a single request upstream for concurrent GET and HEAD
+// requests sharing the same key, and hands a copy of its response to every
+// caller. The shared response body is buffered in memory, each caller reads
+// its own copy. Other methods are sent as is.
+//
+// The shared request is made with the context of the first caller: if it is
+// canceled, every waiting caller gets the error
+type Singleflight struct {
+	Base http.RoundTripper
+	// Key identifies identical requests, DefaultKey by default
+	Key KeyFunc
+
+	mu    sync.Mutex // guards calls
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	res  *http.Response
+	body []byte
+	err  error
+}
+
+// RoundTrip joins the in-flight request with the same key, or sends it
+func (s *Singleflight) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "" && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return s.base().RoundTrip(req)
+	}
+	req = keyRequest(req)
+	key, err := s.key(req)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[string]*flight)
+	}
+	if f, ok := s.calls[key]; ok {
+		s.mu.Unlock()
+		closeBody(req)
+		select {
+		case <-f.done:
+			return f.response(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	s.calls[key] = f
+	s.mu.Unlock()
+
+	f.res, f.err = s.base().RoundTrip(req)
+	if f.err == nil {
+		f.body, f.err = io.ReadAll(f.res.Body)
+		_ = f.res.Body.Close()
+		if f.err != nil {
+			f.err = errors.Wrap(f.err, "unable to read shared response body")
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.calls, key)
+	s.mu.Unlock()
+	close(f.done)
+
+	return f.response(req)
+}
+
+// response returns a copy of the shared response for req
+func (f *flight) response(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	res := new(http.Response)
+	*res = *f.res
+	res.Header = f.res.Header.Clone()
+	res.Body = io.NopCloser(bytes.NewReader(f.body))
+	res.Request = req
+	return res, nil
+}
+
+func (s *Singleflight) key(req *http.Request) (string, error) {
+	if s.Key != nil {
+		return s.Key(req)
+	}
+	return DefaultKey(req)
+}
+
+// Wrap sets base as the Base of Singleflight, so it can be used with Stack
+func (s *Singleflight) Wrap(base http.RoundTripper) http.RoundTripper {
+	s.Base = base
+	return s
+}
+
+func (s *Singleflight) base() http.RoundTripper {
+	if s.Base != nil {
+		return s.Base
+	}
+	return http.DefaultTransport
+}
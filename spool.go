@@ -0,0 +1,66 @@
+package port
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// SpoolToDisk returns a ResponseModifier moving the response bodies larger
+// than threshold bytes to a temporary file in dir (os.TempDir if empty),
+// so large downloads are not held in memory nor tied to the connection. The
+// body is read from the file afterwards, which is removed when the body is
+// closed. Bodies of unknown length are spooled once threshold bytes were read
+func SpoolToDisk(threshold int64, dir string) ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		if resp.Body == nil || resp.Body == http.NoBody {
+			return nil
+		}
+		if resp.ContentLength >= 0 && resp.ContentLength <= threshold {
+			return nil
+		}
+
+		head, err := io.ReadAll(io.LimitReader(resp.Body, threshold+1))
+		if err != nil {
+			return errors.Wrap(err, "unable to read response body")
+		}
+		if int64(len(head)) <= threshold {
+			// small enough after all
+			resp.Body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(head), resp.Body), Closer: resp.Body}
+			return nil
+		}
+
+		f, err := os.CreateTemp(dir, "port-spool-*")
+		if err != nil {
+			return errors.Wrap(err, "unable to create spool file")
+		}
+		spooled := &spoolFile{File: f}
+		_, err = io.Copy(f, io.MultiReader(bytes.NewReader(head), resp.Body))
+		if err == nil {
+			_, err = f.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			_ = spooled.Close()
+			return errors.Wrap(err, "unable to spool response body")
+		}
+		_ = resp.Body.Close()
+		resp.Body = spooled
+		return nil
+	})
+}
+
+// spoolFile is a temporary file removed once closed
+type spoolFile struct {
+	*os.File
+}
+
+func (f *spoolFile) Close() error {
+	err := f.File.Close()
+	if rerr := os.Remove(f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
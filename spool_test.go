@@ -0,0 +1,81 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpoolToDisk(t *testing.T) {
+	large := strings.Repeat("0123456789", 10000)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			// flushing before writing prevents the Content-Length
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, large)
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	dir := t.TempDir()
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithResponseModifier(SpoolToDisk(1000, dir)))
+
+	for _, query := range []string{"", "chunked=1"} {
+		resp, err := c.Get(s.URL + "?" + query)
+		require.NoError(t, err)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, files, 1, query)
+		assert.True(t, strings.HasPrefix(files[0].Name(), "port-spool-"))
+
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, large, string(b), query)
+		require.NoError(t, resp.Body.Close())
+
+		_, err = os.Stat(filepath.Join(dir, files[0].Name()))
+		assert.True(t, os.IsNotExist(err), query)
+	}
+}
+
+func TestSpoolToDisk_Small(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, "small body")
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	dir := t.TempDir()
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithResponseModifier(SpoolToDisk(1000, dir)))
+
+	for _, query := range []string{"", "chunked=1"} {
+		resp, err := c.Get(s.URL + "?" + query)
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, "small body", string(b))
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files, query)
+	}
+}
@@ -0,0 +1,28 @@
+package port
+
+import "net/http"
+
+// Wrapper is a roundtripper sending requests through a base one. Every
+// roundtripper of this package with a Base implements it
+type Wrapper interface {
+	Wrap(base http.RoundTripper) http.RoundTripper
+}
+
+// Stack composes wrappers on top of base and returns the outermost
+// roundtripper. Wrappers are given from the outermost to the innermost:
+//
+//	Stack(http.DefaultTransport, interceptor, retry, limiter)
+//
+// sends requests through interceptor, then retry, then limiter, then
+// http.DefaultTransport. Wrap sets the Base of every wrapper, a wrapper can
+// only be part of a single stack. Nil wrappers are skipped
+func Stack(base http.RoundTripper, wrappers ...Wrapper) http.RoundTripper {
+	rt := base
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		if wrappers[i] == nil {
+			continue
+		}
+		rt = wrappers[i].Wrap(rt)
+	}
+	return rt
+}
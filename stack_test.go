@@ -0,0 +1,77 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStack(t *testing.T) {
+	var calls int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	// the logger is above the retries, it only sees the request of the caller
+	outer := &recordingLogger{}
+	c := s.Client()
+	base := c.Transport
+	c.Transport = Stack(base,
+		NewInterceptor(nil, WithLogger(outer)),
+		NewRetryTransport(nil, 3, noBackoff),
+	)
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, outer.requests, 1)
+
+	// below the retries, it sees every attempt
+	atomic.StoreInt32(&calls, 0)
+	inner := &recordingLogger{}
+	c.Transport = Stack(base,
+		NewRetryTransport(nil, 3, noBackoff),
+		nil,
+		NewInterceptor(nil, WithLogger(inner)),
+	)
+
+	resp, err = c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, inner.requests, 3)
+}
+
+func TestStack_Empty(t *testing.T) {
+	rec := &Recorder{}
+	assert.Equal(t, http.RoundTripper(rec), Stack(rec))
+}
+
+var (
+	_ Wrapper = (*RequestIntercepter)(nil)
+	_ Wrapper = (*RetryTransport)(nil)
+	_ Wrapper = (*CircuitBreaker)(nil)
+	_ Wrapper = (*RateLimiter)(nil)
+	_ Wrapper = (*ConcurrencyLimiter)(nil)
+	_ Wrapper = (*Singleflight)(nil)
+	_ Wrapper = (*CacheTransport)(nil)
+	_ Wrapper = (*CookieTransport)(nil)
+	_ Wrapper = (*Hedging)(nil)
+	_ Wrapper = (*Recorder)(nil)
+	_ Wrapper = (*HostRouter)(nil)
+	_ Wrapper = (*FaultInjector)(nil)
+	_ Wrapper = (*HARRecorder)(nil)
+	_ Wrapper = (*RedirectFollower)(nil)
+	_ Wrapper = (*DigestAuth)(nil)
+)
@@ -0,0 +1,49 @@
+package port
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// maxErrorBodySize bounds the body snippet kept by StatusError
+const maxErrorBodySize = 4 << 10
+
+// StatusError is returned by FailOnStatus for responses with a failing status
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	// Body holds the first bytes of the response body, at most 4KiB
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	msg := "unexpected status " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+	if len(e.Body) > 0 {
+		msg += ": " + string(e.Body)
+	}
+	return msg
+}
+
+// FailOnStatus returns a ResponseModifier turning the responses whose status
+// matches pred (>= 400 if nil) into a *StatusError. The body of such responses
+// is read, up to 4KiB, and closed
+func FailOnStatus(pred func(status int) bool) ResponseModifier {
+	if pred == nil {
+		pred = func(status int) bool { return status >= http.StatusBadRequest }
+	}
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		if !pred(resp.StatusCode) {
+			return nil
+		}
+		serr := &StatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+		}
+		if resp.Body != nil {
+			serr.Body, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+			_ = resp.Body.Close()
+		}
+		return serr
+	})
+}
@@ -0,0 +1,63 @@
+package port
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailOnStatus(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.Header().Set("X-Reason", "gone")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+			_, _ = w.Write([]byte(strings.Repeat("x", 10000)))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	it := NewRequestInterceptor(c.Transport, nil)
+	it.ResponseModifier = FailOnStatus(nil)
+	c.Transport = it
+
+	_, err := c.Get(s.URL + "/missing")
+	require.Error(t, err)
+	var serr *StatusError
+	require.True(t, errors.As(err, &serr))
+	assert.Equal(t, http.StatusNotFound, serr.StatusCode)
+	assert.Equal(t, "gone", serr.Header.Get("X-Reason"))
+	assert.Len(t, serr.Body, maxErrorBodySize)
+	assert.True(t, strings.HasPrefix(string(serr.Body), `{"error":"not found"}`))
+
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(b))
+}
+
+func TestFailOnStatus_Predicate(t *testing.T) {
+	mod := FailOnStatus(func(status int) bool { return status != http.StatusOK })
+
+	err := mod.ModifyResponse(&http.Response{StatusCode: http.StatusOK, Body: http.NoBody})
+	require.NoError(t, err)
+
+	err = mod.ModifyResponse(&http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody})
+	var serr *StatusError
+	require.True(t, errors.As(err, &serr))
+	assert.Equal(t, "unexpected status 202 Accepted", serr.Error())
+}
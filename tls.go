@@ -0,0 +1,37 @@
+package port
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWeakTLS is returned by RequireTLS for responses received over a
+// connection weaker than required
+var ErrWeakTLS = errors.New("connection is not secure enough")
+
+// RequireTLS returns a ResponseModifier failing with ErrWeakTLS, and closing
+// the body, when the response was received over a TLS version older than
+// minVersion, e.g. tls.VersionTLS12, or without TLS. The version is only known
+// once the connection is established, the request has been sent already: set
+// the MinVersion of the TLS config of the base transport to never send it
+// over a weaker connection, RequireTLS then guards against a misconfigured
+// transport
+func RequireTLS(minVersion uint16) ResponseModifier {
+	return ResponseModifierFunc(func(resp *http.Response) error {
+		var err error
+		switch {
+		case resp.TLS == nil:
+			err = errors.Wrap(ErrWeakTLS, "no TLS")
+		case resp.TLS.Version < minVersion:
+			err = errors.Wrapf(ErrWeakTLS, "%s, %s required", tls.VersionName(resp.TLS.Version), tls.VersionName(minVersion))
+		default:
+			return nil
+		}
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	})
+}
@@ -0,0 +1,49 @@
+package port
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireTLS(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	// the client caps the negotiated version to TLS 1.2
+	base := s.Client().Transport.(*http.Transport).Clone()
+	base.TLSClientConfig.MaxVersion = tls.VersionTLS12
+
+	c := &http.Client{Transport: NewInterceptor(base, WithResponseModifier(RequireTLS(tls.VersionTLS12)))}
+	resp, err := c.Get(s.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	c = &http.Client{Transport: NewInterceptor(base, WithResponseModifier(RequireTLS(tls.VersionTLS13)))}
+	_, err = c.Get(s.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrWeakTLS))
+	assert.Contains(t, err.Error(), "TLS 1.2, TLS 1.3 required")
+}
+
+func TestRequireTLS_PlainHTTP(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithResponseModifier(RequireTLS(tls.VersionTLS12)))
+	_, err := c.Get(s.URL)
+	assert.True(t, errors.Is(err, ErrWeakTLS))
+}
@@ -0,0 +1,82 @@
+package port
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+)
+
+// SpanContext is the part of a span propagated to the upstream service
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Sampled    bool
+	TraceState string
+}
+
+// IsValid reports whether both the trace and span ids are set
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+// SpanExtractor returns the current span of ctx, if any. It is the bridge
+// with the tracing SDK in use
+type SpanExtractor func(ctx context.Context) (SpanContext, bool)
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, to be read by
+// SpanFromContext
+func ContextWithSpan(ctx context.Context, span SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext is the SpanExtractor reading spans stored with
+// ContextWithSpan
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	span, ok := ctx.Value(spanKey{}).(SpanContext)
+	return span, ok
+}
+
+// TraceContext returns a RequestModifier writing the W3C traceparent and
+// tracestate headers from the span found in the request context by extract
+// (SpanFromContext if nil). Requests without a valid span are left untouched
+func TraceContext(extract SpanExtractor) RequestModifier {
+	return spanModifier(extract, func(req *http.Request, span SpanContext) {
+		flags := "00"
+		if span.Sampled {
+			flags = "01"
+		}
+		req.Header.Set("traceparent", "00-"+hex.EncodeToString(span.TraceID[:])+"-"+hex.EncodeToString(span.SpanID[:])+"-"+flags)
+		if span.TraceState != "" {
+			req.Header.Set("tracestate", span.TraceState)
+		}
+	})
+}
+
+// TraceContextB3 returns a RequestModifier writing the B3 single header from
+// the span found in the request context by extract (SpanFromContext if nil).
+// Requests without a valid span are left untouched
+func TraceContextB3(extract SpanExtractor) RequestModifier {
+	return spanModifier(extract, func(req *http.Request, span SpanContext) {
+		sampled := "0"
+		if span.Sampled {
+			sampled = "1"
+		}
+		req.Header.Set("b3", hex.EncodeToString(span.TraceID[:])+"-"+hex.EncodeToString(span.SpanID[:])+"-"+sampled)
+	})
+}
+
+func spanModifier(extract SpanExtractor, write func(req *http.Request, span SpanContext)) RequestModifier {
+	if extract == nil {
+		extract = SpanFromContext
+	}
+	return RequestModifierFunc(func(req *http.Request) error {
+		span, ok := extract(req.Context())
+		if !ok || !span.IsValid() {
+			return nil
+		}
+		write(req, span)
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package port
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSpan(t *testing.T) SpanContext {
+	var span SpanContext
+	_, err := hex.Decode(span.TraceID[:], []byte("4bf92f3577b34da6a3ce929d0e0e4736"))
+	require.NoError(t, err)
+	_, err = hex.Decode(span.SpanID[:], []byte("00f067aa0ba902b7"))
+	require.NoError(t, err)
+	span.Sampled = true
+	return span
+}
+
+func TestTraceContext(t *testing.T) {
+	span := testSpan(t)
+	span.TraceState = "congo=t61rcWkgMzE"
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req = req.WithContext(ContextWithSpan(req.Context(), span))
+
+	require.NoError(t, TraceContext(nil).Intercept(req))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.Header.Get("traceparent"))
+	assert.Equal(t, "congo=t61rcWkgMzE", req.Header.Get("tracestate"))
+}
+
+func TestTraceContext_Extractor(t *testing.T) {
+	span := testSpan(t)
+	span.Sampled = false
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	mod := TraceContext(func(ctx context.Context) (SpanContext, bool) {
+		return span, true
+	})
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", req.Header.Get("traceparent"))
+	_, ok := req.Header["Tracestate"]
+	assert.False(t, ok)
+}
+
+func TestTraceContextB3(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+	req = req.WithContext(ContextWithSpan(req.Context(), testSpan(t)))
+
+	require.NoError(t, TraceContextB3(nil).Intercept(req))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", req.Header.Get("b3"))
+}
+
+func TestTraceContext_NoSpan(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, TraceContext(nil).Intercept(req))
+	require.NoError(t, TraceContextB3(nil).Intercept(req))
+
+	// an invalid span is ignored as well
+	req = req.WithContext(ContextWithSpan(req.Context(), SpanContext{Sampled: true}))
+	require.NoError(t, TraceContext(nil).Intercept(req))
+
+	assert.Empty(t, req.Header)
+}
@@ -0,0 +1,230 @@
+package port
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AddQueryParams returns a RequestModifier merging params into the request
+// query. Values are appended to the ones already present for the same key.
+// The resulting query is re-encoded, sorted by key
+func AddQueryParams(params url.Values) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		q := req.URL.Query()
+		for k, vs := range params {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+// SetQueryParams returns a RequestModifier merging params into the request
+// query. Values replace the ones already present for the same key, other keys
+// are kept. The resulting query is re-encoded, sorted by key
+func SetQueryParams(params url.Values) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		q := req.URL.Query()
+		for k, vs := range params {
+			q[k] = append([]string(nil), vs...)
+		}
+		req.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+// RewriteURL returns a RequestModifier sending requests to target: its scheme
+// and host replace the request ones, and its path, if any, is prepended to the
+// request path. The request query is kept. When the host changes, req.Host is
+// updated as well so virtual hosts see the new name
+func RewriteURL(target *url.URL) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		if target.Scheme != "" {
+			req.URL.Scheme = target.Scheme
+		}
+		if target.Host != "" && target.Host != req.URL.Host {
+			req.URL.Host = target.Host
+			req.Host = target.Host
+		}
+		if target.Path != "" {
+			rawPath := ""
+			if target.RawPath != "" || req.URL.RawPath != "" {
+				rawPath = joinPath(target.EscapedPath(), req.URL.EscapedPath())
+			}
+			req.URL.Path = joinPath(target.Path, req.URL.Path)
+			req.URL.RawPath = rawPath
+		}
+		return nil
+	})
+}
+
+// OverrideHost returns a RequestModifier sending host in the Host header while
+// still connecting to the URL host, e.g. to reach a virtual host through a
+// load balancer address. TLS connections use the URL host for SNI and
+// certificate verification
+func OverrideHost(host string) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		req.Host = host
+		return nil
+	})
+}
+
+// ErrInsecureScheme is returned by RequireHTTPS when a request is not sent
+// over https
+var ErrInsecureScheme = errors.New("request must use https")
+
+// HTTPSMode tells RequireHTTPS what to do with plaintext requests
+type HTTPSMode int
+
+const (
+	// HTTPSReject fails plaintext requests with ErrInsecureScheme
+	HTTPSReject HTTPSMode = iota
+	// HTTPSUpgrade sends plaintext requests over https instead
+	HTTPSUpgrade
+)
+
+// RequireHTTPS returns a RequestModifier making sure requests never go out
+// over plain http. Depending on mode, http URLs are either rejected or
+// upgraded to https, dropping an explicit :80 port and keeping the rest of the
+// URL. Other schemes are rejected in both modes
+func RequireHTTPS(mode HTTPSMode) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		switch strings.ToLower(req.URL.Scheme) {
+		case "https":
+			return nil
+		case "http":
+			if mode != HTTPSUpgrade {
+				return errors.Wrap(ErrInsecureScheme, req.URL.Redacted())
+			}
+			req.URL.Scheme = "https"
+			req.URL.Host = strings.TrimSuffix(req.URL.Host, ":80")
+			req.Host = strings.TrimSuffix(req.Host, ":80")
+			return nil
+		}
+		return errors.Wrapf(ErrInsecureScheme, "unsupported scheme %q", req.URL.Scheme)
+	})
+}
+
+// NormalizeOptions selects the normalizations applied by NormalizeURL
+type NormalizeOptions struct {
+	// LowercaseHost lowercases the host name
+	LowercaseHost bool
+	// RemoveDefaultPort removes :80 from http and :443 from https URLs
+	RemoveDefaultPort bool
+	// SortQuery sorts the query parameters by key, keeping the order of the
+	// values of a key
+	SortQuery bool
+	// CollapseSlashes replaces consecutive slashes of the path with one
+	CollapseSlashes bool
+	// ResolveDotSegments removes the "." and ".." segments of the path
+	ResolveDotSegments bool
+}
+
+// NormalizeURL returns a RequestModifier rewriting the request URL into a
+// canonical form, as selected by opts. The URL is copied before being
+// changed, the one of the caller is never modified
+func NormalizeURL(opts NormalizeOptions) RequestModifier {
+	return RequestModifierFunc(func(req *http.Request) error {
+		u := *req.URL
+		if opts.LowercaseHost {
+			u.Host = strings.ToLower(u.Host)
+			req.Host = strings.ToLower(req.Host)
+		}
+		if opts.RemoveDefaultPort {
+			u.Host = removeDefaultPort(u.Scheme, u.Host)
+			req.Host = removeDefaultPort(u.Scheme, req.Host)
+		}
+		if opts.SortQuery && u.RawQuery != "" {
+			if q, err := url.ParseQuery(u.RawQuery); err == nil {
+				u.RawQuery = q.Encode()
+			}
+		}
+		if opts.CollapseSlashes || opts.ResolveDotSegments {
+			escaped := u.EscapedPath()
+			if opts.CollapseSlashes {
+				escaped = collapseSlashes(escaped)
+			}
+			if opts.ResolveDotSegments {
+				escaped = removeDotSegments(escaped)
+			}
+			path, err := url.PathUnescape(escaped)
+			if err != nil {
+				return errors.Wrap(err, "unable to normalize path")
+			}
+			u.Path = path
+			u.RawPath = ""
+			if u.EscapedPath() != escaped {
+				u.RawPath = escaped
+			}
+		}
+		req.URL = &u
+		return nil
+	})
+}
+
+// removeDefaultPort strips the port of host when it is the default one of
+// scheme
+func removeDefaultPort(scheme, host string) string {
+	switch strings.ToLower(scheme) {
+	case "http":
+		return strings.TrimSuffix(host, ":80")
+	case "https":
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
+// collapseSlashes replaces runs of slashes in p with a single one
+func collapseSlashes(p string) string {
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	return p
+}
+
+// removeDotSegments resolves the "." and ".." segments of p as described in
+// RFC 3986 section 5.2.4
+func removeDotSegments(p string) string {
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	last := len(segments) - 1
+	for i, seg := range segments {
+		switch seg {
+		case ".":
+		case "..":
+			// never go above the root
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+			continue
+		}
+		if i == last {
+			// "/a/." and "/a/b/.." both designate the directory "/a/"
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// joinPath joins two url paths with exactly one slash between them
+func joinPath(a, b string) string {
+	if b == "" {
+		return a
+	}
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
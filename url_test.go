@@ -0,0 +1,268 @@
+package port
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddQueryParams(t *testing.T) {
+	var received url.Values
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query()
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, AddQueryParams(url.Values{
+		"api_key": {"k&y=1 2"},
+		"tag":     {"b"},
+	}))
+
+	req, err := http.NewRequest("GET", s.URL+"?tag=a&page=2", nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "k&y=1 2", received.Get("api_key"))
+	assert.Equal(t, []string{"a", "b"}, received["tag"])
+	assert.Equal(t, "2", received.Get("page"))
+	// the caller request is untouched
+	assert.Equal(t, "tag=a&page=2", req.URL.RawQuery)
+}
+
+func TestAddQueryParams_Encoding(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/?q=1", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, AddQueryParams(url.Values{"api_key": {"a b/c&d"}}).Intercept(req))
+	assert.Equal(t, "api_key=a+b%2Fc%26d&q=1", req.URL.RawQuery)
+}
+
+func TestSetQueryParams(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/?tag=a&tag=b&page=2", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, SetQueryParams(url.Values{"tag": {"c"}}).Intercept(req))
+	q := req.URL.Query()
+	assert.Equal(t, []string{"c"}, q["tag"])
+	assert.Equal(t, "2", q.Get("page"))
+}
+
+func TestRewriteURL(t *testing.T) {
+	var received *http.Request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	target, err := url.Parse(s.URL + "/api/")
+	require.NoError(t, err)
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, RewriteURL(target))
+
+	req, err := http.NewRequest("GET", "https://elsewhere.example.com/users/1?active=true", nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.NotNil(t, received)
+	assert.Equal(t, target.Host, received.Host)
+	assert.Equal(t, "/api/users/1", received.URL.Path)
+	assert.Equal(t, "active=true", received.URL.RawQuery)
+	assert.Equal(t, "elsewhere.example.com", req.URL.Host)
+}
+
+func TestRewriteURL_Paths(t *testing.T) {
+	cases := []struct {
+		target string
+		path   string
+		want   string
+	}{
+		{"http://backend", "/users", "/users"},
+		{"http://backend/api", "/users", "/api/users"},
+		{"http://backend/api/", "/users", "/api/users"},
+		{"http://backend/api/", "", "/api/"},
+		{"http://backend/api", "users", "/api/users"},
+	}
+	for _, c := range cases {
+		target, err := url.Parse(c.target)
+		require.NoError(t, err)
+		req := &http.Request{URL: &url.URL{Scheme: "http", Host: "front", Path: c.path}, Header: http.Header{}}
+
+		require.NoError(t, RewriteURL(target).Intercept(req))
+		assert.Equal(t, c.want, req.URL.Path, c.target+" + "+c.path)
+		assert.Equal(t, "backend", req.URL.Host)
+		assert.Equal(t, "backend", req.Host)
+	}
+}
+
+func TestRewriteURL_Escaped(t *testing.T) {
+	target, err := url.Parse("http://backend/api")
+	require.NoError(t, err)
+	req, err := http.NewRequest("GET", "http://front/files/a%2Fb", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, RewriteURL(target).Intercept(req))
+	assert.Equal(t, "/api/files/a%2Fb", req.URL.EscapedPath())
+}
+
+func TestNormalizeURL(t *testing.T) {
+	const raw = "http://Example.COM:80//a/./b//../c?z=1&a=2&a=1"
+	cases := []struct {
+		name string
+		opts NormalizeOptions
+		want string
+	}{
+		{"none", NormalizeOptions{}, raw},
+		{"host", NormalizeOptions{LowercaseHost: true}, "http://example.com:80//a/./b//../c?z=1&a=2&a=1"},
+		{"port", NormalizeOptions{RemoveDefaultPort: true}, "http://Example.COM//a/./b//../c?z=1&a=2&a=1"},
+		{"query", NormalizeOptions{SortQuery: true}, "http://Example.COM:80//a/./b//../c?a=2&a=1&z=1"},
+		{"slashes", NormalizeOptions{CollapseSlashes: true}, "http://Example.COM:80/a/./b/../c?z=1&a=2&a=1"},
+		{"dots", NormalizeOptions{ResolveDotSegments: true}, "http://Example.COM:80//a/b/c?z=1&a=2&a=1"},
+		{"all", NormalizeOptions{
+			LowercaseHost:      true,
+			RemoveDefaultPort:  true,
+			SortQuery:          true,
+			CollapseSlashes:    true,
+			ResolveDotSegments: true,
+		}, "http://example.com/a/c?a=2&a=1&z=1"},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest("GET", raw, nil)
+		require.NoError(t, err)
+		orig := req.URL
+
+		require.NoError(t, NormalizeURL(c.opts).Intercept(req))
+		assert.Equal(t, c.want, req.URL.String(), c.name)
+		assert.Equal(t, raw, orig.String(), c.name)
+	}
+}
+
+func TestNormalizeURL_Ports(t *testing.T) {
+	opts := NormalizeOptions{RemoveDefaultPort: true}
+	for raw, want := range map[string]string{
+		"https://example.com:443/": "https://example.com/",
+		"https://example.com:80/":  "https://example.com:80/",
+		"http://example.com:8080/": "http://example.com:8080/",
+	} {
+		req, err := http.NewRequest("GET", raw, nil)
+		require.NoError(t, err)
+		require.NoError(t, NormalizeURL(opts).Intercept(req))
+		assert.Equal(t, want, req.URL.String())
+	}
+}
+
+func TestNormalizeURL_DotSegments(t *testing.T) {
+	opts := NormalizeOptions{ResolveDotSegments: true}
+	for path, want := range map[string]string{
+		"/a/b/../c":     "/a/c",
+		"/a/.":          "/a/",
+		"/a/b/..":       "/a/",
+		"/../../a":      "/a",
+		"/a/./b/./":     "/a/b/",
+		"/files/x%2F..": "/files/x%2F..",
+	} {
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		require.NoError(t, err)
+		require.NoError(t, NormalizeURL(opts).Intercept(req))
+		assert.Equal(t, want, req.URL.EscapedPath(), path)
+	}
+}
+
+func TestNormalizeURL_Interceptor(t *testing.T) {
+	var received string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.RequestURI()
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, NormalizeURL(NormalizeOptions{CollapseSlashes: true, SortQuery: true}))
+
+	resp, err := c.Get(s.URL + "//a///b?b=2&a=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "/a/b?a=1&b=2", received)
+}
+
+func TestRequireHTTPS_Reject(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://example.com/a":     true,
+		"HTTPS://example.com:8443/": true,
+		"http://example.com/a":      false,
+		"http://example.com:8080/a": false,
+		"ws://example.com/":         false,
+	} {
+		req, err := http.NewRequest("GET", raw, nil)
+		require.NoError(t, err)
+		err = RequireHTTPS(HTTPSReject).Intercept(req)
+		if ok {
+			assert.NoError(t, err, raw)
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrInsecureScheme), raw)
+	}
+}
+
+func TestRequireHTTPS_Upgrade(t *testing.T) {
+	for raw, want := range map[string]string{
+		"http://example.com/a?b=c#d":     "https://example.com/a?b=c#d",
+		"http://example.com:80/a":        "https://example.com/a",
+		"http://user@example.com:8080/a": "https://user@example.com:8080/a",
+		"https://example.com:443/a":      "https://example.com:443/a",
+	} {
+		req, err := http.NewRequest("GET", raw, nil)
+		require.NoError(t, err)
+		req.Host = req.URL.Host
+		require.NoError(t, RequireHTTPS(HTTPSUpgrade).Intercept(req), raw)
+		assert.Equal(t, want, req.URL.String())
+		assert.Equal(t, req.URL.Host, req.Host)
+	}
+
+	req, err := http.NewRequest("GET", "ftp://example.com/", nil)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(RequireHTTPS(HTTPSUpgrade).Intercept(req), ErrInsecureScheme))
+}
+
+func TestOverrideHost(t *testing.T) {
+	var host string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewRequestInterceptor(c.Transport, OverrideHost("virtual.example.com"))
+
+	req, err := http.NewRequest("GET", s.URL, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "virtual.example.com", host)
+	// the caller request is untouched
+	assert.NotEqual(t, "virtual.example.com", req.Host)
+	assert.Equal(t, "virtual.example.com", resp.Request.Host)
+}
@@ -0,0 +1,80 @@
+package port
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultVersionMediaType is the media type VersionSpec uses with
+// VersionHeader when none is given
+const DefaultVersionMediaType = "application/vnd.api+json"
+
+// VersionStrategy is the way APIVersion selects the API version
+type VersionStrategy int
+
+const (
+	// VersionHeader sets the version as a parameter of the media type, e.g.
+	// Accept: application/vnd.api+json;version=2
+	VersionHeader VersionStrategy = iota
+	// VersionPath prefixes the request path with the version, e.g. /v2/users
+	VersionPath
+)
+
+// VersionSpec configures APIVersion
+type VersionSpec struct {
+	// Version is the API version, e.g. "2"
+	Version string
+	// Strategy is how the version is sent, VersionHeader by default
+	Strategy VersionStrategy
+	// Header receives the media type with VersionHeader, Accept by default
+	Header string
+	// MediaType is the media type carrying the version with VersionHeader,
+	// DefaultVersionMediaType by default
+	MediaType string
+	// Prefix is the path prefix used with VersionPath, "/v" followed by
+	// Version by default
+	Prefix string
+}
+
+// APIVersion returns a RequestModifier selecting the API version of spec.
+// With VersionHeader, the header is replaced by the versioned media type. With
+// VersionPath, the prefix is added in front of the request path unless it is
+// already there, so the modifier can run several times on the same request
+func APIVersion(spec VersionSpec) RequestModifier {
+	if spec.Strategy == VersionPath {
+		prefix := spec.Prefix
+		if prefix == "" {
+			prefix = "/v" + spec.Version
+		}
+		prefix = "/" + strings.Trim(prefix, "/")
+		return RequestModifierFunc(func(req *http.Request) error {
+			if hasPathPrefix(req.URL.Path, prefix) {
+				return nil
+			}
+			req.URL.Path = prefix + "/" + strings.TrimPrefix(req.URL.Path, "/")
+			if req.URL.RawPath != "" {
+				req.URL.RawPath = prefix + "/" + strings.TrimPrefix(req.URL.RawPath, "/")
+			}
+			return nil
+		})
+	}
+
+	header := spec.Header
+	if header == "" {
+		header = "Accept"
+	}
+	mediaType := spec.MediaType
+	if mediaType == "" {
+		mediaType = DefaultVersionMediaType
+	}
+	value := mediaType + ";version=" + spec.Version
+	return RequestModifierFunc(func(req *http.Request) error {
+		req.Header.Set(header, value)
+		return nil
+	})
+}
+
+// hasPathPrefix tells whether prefix is the whole first segments of path
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
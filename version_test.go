@@ -0,0 +1,71 @@
+package port
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersion_Header(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://api.example.com/users", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+
+	mod := APIVersion(VersionSpec{Version: "2"})
+	require.NoError(t, mod.Intercept(req))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, []string{"application/vnd.api+json;version=2"}, req.Header.Values("Accept"))
+	assert.Equal(t, "/users", req.URL.Path)
+
+	req.Header = make(http.Header)
+	require.NoError(t, APIVersion(VersionSpec{Version: "3", Header: "Content-Type", MediaType: "application/vnd.acme+json"}).Intercept(req))
+	assert.Equal(t, "application/vnd.acme+json;version=3", req.Header.Get("Content-Type"))
+	assert.Empty(t, req.Header.Get("Accept"))
+}
+
+func TestAPIVersion_Path(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("path", r.URL.EscapedPath())
+	}))
+
+	defer func() {
+		s.Close()
+	}()
+
+	c := s.Client()
+	c.Transport = NewInterceptor(c.Transport, WithRequestModifier(APIVersion(VersionSpec{Version: "2", Strategy: VersionPath})))
+
+	for rawurl, want := range map[string]string{
+		s.URL:                "/v2/",
+		s.URL + "/users":     "/v2/users",
+		s.URL + "/a%2Fb":     "/v2/a%2Fb",
+		s.URL + "/v2":        "/v2",
+		s.URL + "/v2/users":  "/v2/users",
+		s.URL + "/v20/users": "/v2/v20/users",
+		s.URL + "/api/v2/x":  "/v2/api/v2/x",
+	} {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		require.NoError(t, err)
+		path := req.URL.Path
+		resp, err := c.Do(req)
+		require.NoError(t, err)
+		discardResponse(resp)
+		assert.Equal(t, want, resp.Header.Get("path"), rawurl)
+		// the URL of the caller is left untouched
+		assert.Equal(t, path, req.URL.Path, rawurl)
+	}
+}
+
+func TestAPIVersion_PathIdempotent(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://api.example.com/users?page=2", nil)
+	require.NoError(t, err)
+
+	mod := APIVersion(VersionSpec{Strategy: VersionPath, Prefix: "api/v1/"})
+	require.NoError(t, mod.Intercept(req))
+	require.NoError(t, mod.Intercept(req))
+	assert.Equal(t, "http://api.example.com/api/v1/users?page=2", req.URL.String())
+	assert.Empty(t, req.Header.Get("Accept"))
+}